@@ -0,0 +1,288 @@
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// BodyCaptureConfig controls MiddlewareBodyCapture.
+type BodyCaptureConfig struct {
+	// Skipper skips the middleware for matching requests.
+	Skipper middleware.Skipper
+	// HTTPSkipper is Skipper for HTTPBodyCapture.
+	HTTPSkipper HTTPSkipper
+
+	// MaxBytes is the most that is captured of each body. Defaults to 4096.
+	MaxBytes int
+	// ContentTypes are the media types that are captured. Defaults to application/json
+	// (which also covers +json types) and application/x-www-form-urlencoded.
+	ContentTypes []string
+
+	// Redact lists JSON paths whose values are logged as [REDACTED], e.g. $.card.number
+	// or $.items[*].token. For forms, $.name redacts the field name.
+	// Truncated bodies cannot be redacted reliably and are not logged when this is set.
+	Redact []string
+}
+
+// DefaultBodyCaptureConfig is the preset used when fields are left empty.
+var DefaultBodyCaptureConfig = BodyCaptureConfig{
+	MaxBytes:     4096,
+	ContentTypes: []string{echo.MIMEApplicationJSON, echo.MIMEApplicationForm},
+}
+
+// MiddlewareBodyCapture tees the request and response bodies, up to MaxBytes each, and
+// hands them to the request logger, which adds them as req_body and resp_body for
+// failed requests, requests kept by tail sampling and requests with debug enabled.
+// Writes and flushes go through untouched, so streaming responses keep working.
+//
+// Install it in front of MiddlewareRequestLoggerWithConfig. The request body is captured
+// as the handler reads it.
+func MiddlewareBodyCapture(config BodyCaptureConfig) echo.MiddlewareFunc {
+	bc := newBodyCapturer(config)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if bc.Skipper(c) {
+				return next(c)
+			}
+			res := c.Response()
+			rec, req := bc.wrap(res.Writer, c.Request())
+			res.Writer = rec
+			c.SetRequest(req)
+			return next(c)
+		}
+	}
+}
+
+// bodyCapturer is a BodyCaptureConfig with defaults applied and paths parsed.
+type bodyCapturer struct {
+	BodyCaptureConfig
+	redact [][]string
+}
+
+func newBodyCapturer(config BodyCaptureConfig) *bodyCapturer {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.HTTPSkipper == nil {
+		config.HTTPSkipper = defaultHTTPSkipper
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultBodyCaptureConfig.MaxBytes
+	}
+	if len(config.ContentTypes) == 0 {
+		config.ContentTypes = DefaultBodyCaptureConfig.ContentTypes
+	}
+	bc := &bodyCapturer{BodyCaptureConfig: config}
+	for _, p := range config.Redact {
+		segs, err := parseJSONPath(p)
+		if err != nil {
+			panic("xlog: " + err.Error())
+		}
+		bc.redact = append(bc.redact, segs)
+	}
+	return bc
+}
+
+// wrap installs the capture on w and r.
+func (bc *bodyCapturer) wrap(w http.ResponseWriter, r *http.Request) (*responseRecorder, *http.Request) {
+	capture := &bodyCapture{
+		capturer: bc,
+		reqType:  r.Header.Get("Content-Type"),
+		req:      captureBuffer{limit: bc.MaxBytes},
+		resp:     captureBuffer{limit: bc.MaxBytes},
+	}
+	rec := newResponseRecorder(w)
+	rec.capture = &capture.resp
+	capture.rec = rec
+
+	r = r.WithContext(context.WithValue(r.Context(), ctxBodyCaptureKey{}, capture))
+	if r.Body != nil && r.Body != http.NoBody && bc.allowed(capture.reqType) {
+		r.Body = teeReadCloser{io.TeeReader(r.Body, &capture.req), r.Body}
+	}
+	return rec, r
+}
+
+func (bc *bodyCapturer) allowed(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(bc.ContentTypes, mt) ||
+		strings.HasSuffix(mt, "+json") && slices.Contains(bc.ContentTypes, echo.MIMEApplicationJSON)
+}
+
+// Key for the bodies captured for the request.
+type ctxBodyCaptureKey struct{}
+
+type bodyCapture struct {
+	capturer *bodyCapturer
+	reqType  string
+	req      captureBuffer
+	resp     captureBuffer
+	rec      *responseRecorder
+}
+
+// bodyAttrs returns the captured bodies of the request in ctx, if any.
+func bodyAttrs(ctx context.Context) []slog.Attr {
+	capture, _ := ctx.Value(ctxBodyCaptureKey{}).(*bodyCapture)
+	if capture == nil {
+		return nil
+	}
+	var attrs []slog.Attr
+	if a, ok := capture.capturer.attr("req_body", capture.reqType, &capture.req); ok {
+		attrs = append(attrs, a)
+	}
+	if a, ok := capture.capturer.attr("resp_body", capture.rec.Header().Get("Content-Type"), &capture.resp); ok {
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+// attr renders a captured body: redacted JSON as is, forms as a redacted query string.
+func (bc *bodyCapturer) attr(key, contentType string, b *captureBuffer) (slog.Attr, bool) {
+	if b.total == 0 || !bc.allowed(contentType) {
+		return slog.Attr{}, false
+	}
+	if b.truncated() {
+		if len(bc.redact) > 0 {
+			return slog.String(key, "[TRUNCATED]"), true
+		}
+		return slog.String(key, b.buf.String()+"..."), true
+	}
+
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt == echo.MIMEApplicationForm {
+		form, err := url.ParseQuery(b.buf.String())
+		if err != nil {
+			return slog.String(key, "[INVALID]"), true
+		}
+		for _, segs := range bc.redact {
+			if len(segs) == 1 && form.Has(segs[0]) {
+				form[segs[0]] = []string{redacted}
+			}
+		}
+		return slog.String(key, form.Encode()), true
+	}
+
+	if len(bc.redact) == 0 && json.Valid(b.buf.Bytes()) {
+		return slog.Any(key, json.RawMessage(b.buf.Bytes())), true
+	}
+	dec := json.NewDecoder(bytes.NewReader(b.buf.Bytes()))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return slog.String(key, "[INVALID]"), true
+	}
+	for _, segs := range bc.redact {
+		v = redactPath(v, segs)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return slog.String(key, "[INVALID]"), true
+	}
+	return slog.Any(key, json.RawMessage(out)), true
+}
+
+// captureBuffer keeps the first limit bytes written to it and counts the rest.
+type captureBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	total int64
+}
+
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+func (b *captureBuffer) truncated() bool {
+	return b.total > int64(b.buf.Len())
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// parseJSONPath splits $.a.b[*].c into a, b, *, c. Indexes are kept as digits.
+func parseJSONPath(path string) ([]string, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, &jsonPathError{path}
+	}
+	var segs []string
+	for rest != "" {
+		var seg string
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			seg, rest = rest[1:1+end], rest[1+end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, &jsonPathError{path}
+			}
+			seg, rest = strings.Trim(rest[1:end], `'"`), rest[end+1:]
+		default:
+			return nil, &jsonPathError{path}
+		}
+		if seg == "" {
+			return nil, &jsonPathError{path}
+		}
+		segs = append(segs, seg)
+	}
+	if len(segs) == 0 {
+		return nil, &jsonPathError{path}
+	}
+	return segs, nil
+}
+
+type jsonPathError struct {
+	path string
+}
+
+func (e *jsonPathError) Error() string {
+	return "invalid redact path " + strconv.Quote(e.path)
+}
+
+// redactPath replaces the values at segs in v. * matches every key or element.
+func redactPath(v any, segs []string) any {
+	if len(segs) == 0 {
+		return redacted
+	}
+	seg, rest := segs[0], segs[1:]
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if seg == "*" || seg == k {
+				v[k] = redactPath(child, rest)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			if seg == "*" || seg == strconv.Itoa(i) {
+				v[i] = redactPath(child, rest)
+			}
+		}
+	}
+	return v
+}
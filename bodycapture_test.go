@@ -0,0 +1,130 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newBodyCaptureEcho(buf *bytes.Buffer, level slog.Level, config BodyCaptureConfig) *echo.Echo {
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: level}))
+
+	e := echo.New()
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.Use(MiddlewareRequestLoggerWithConfig(RequestLoggerConfig{LogStatus: true}))
+	e.Use(MiddlewareBodyCapture(config))
+	e.POST("/pay", func(c echo.Context) error {
+		var in map[string]any
+		if err := json.NewDecoder(c.Request().Body).Decode(&in); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusPaymentRequired, map[string]any{"token": "tok_1", "ok": false})
+	})
+	e.POST("/ok", func(c echo.Context) error {
+		_, _ = io.ReadAll(c.Request().Body)
+		return c.JSON(http.StatusOK, map[string]any{"ok": true})
+	})
+	return e
+}
+
+func Test_MiddlewareBodyCapture_FailedRequestRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	e := newBodyCaptureEcho(&buf, slog.LevelInfo, BodyCaptureConfig{
+		Redact: []string{"$.card.number", "$.items[*].secret", "$.token"},
+	})
+
+	body := `{"card":{"number":"4242424242424242","exp":"12/30"},"items":[{"secret":"a","n":1},{"secret":"b","n":2}]}`
+	req := httptest.NewRequest(http.MethodPost, "/pay", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	var logged struct {
+		Status   int            `json:"status"`
+		ReqBody  map[string]any `json:"req_body"`
+		RespBody map[string]any `json:"resp_body"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &logged); err != nil {
+		t.Fatalf("unmarshal log: %v\n%s", err, buf.String())
+	}
+	want := map[string]any{
+		"card":  map[string]any{"number": redacted, "exp": "12/30"},
+		"items": []any{map[string]any{"secret": redacted, "n": float64(1)}, map[string]any{"secret": redacted, "n": float64(2)}},
+	}
+	if logged.Status != http.StatusPaymentRequired || !reflect.DeepEqual(logged.ReqBody, want) {
+		t.Errorf("unexpected req_body: %+v", logged)
+	}
+	if logged.RespBody["token"] != redacted || logged.RespBody["ok"] != false {
+		t.Errorf("unexpected resp_body: %+v", logged.RespBody)
+	}
+}
+
+func Test_MiddlewareBodyCapture_OnlyWhenNeeded(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		level slog.Level
+		want  bool
+	}{
+		{"success", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := newBodyCaptureEcho(&buf, tt.level, BodyCaptureConfig{})
+
+			req := httptest.NewRequest(http.MethodPost, "/ok", strings.NewReader("a=1&b=2"))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			e.ServeHTTP(httptest.NewRecorder(), req)
+
+			got := strings.Contains(buf.String(), `"req_body":"a=1&b=2"`) &&
+				strings.Contains(buf.String(), `"resp_body":{"ok":true}`)
+			if got != tt.want {
+				t.Errorf("expected bodies logged=%v, got %s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+func Test_HTTPBodyCapture_Streaming(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	var flushed bool
+	h := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"chunk":`))
+		flushed = http.NewResponseController(w).Flush() == nil
+		_, _ = w.Write([]byte(`"0123456789"}`))
+	}))
+	h = HTTPBodyCapture(BodyCaptureConfig{MaxBytes: 8})(HTTPRequestLogger(RequestLoggerConfig{})(h))
+	h = HTTPAttachDefaults(logger)(h)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !flushed || !rec.Flushed || rec.Body.String() != `{"chunk":"0123456789"}` {
+		t.Errorf("expected the response to stream through untouched, got flushed=%v %q", flushed, rec.Body.String())
+	}
+	if !strings.Contains(buf.String(), `"resp_body":"{\"chunk\"..."`) {
+		t.Errorf("expected a truncated resp_body, got %s", buf.String())
+	}
+}
+
+func Test_parseJSONPath(t *testing.T) {
+	segs, err := parseJSONPath("$.items[*].card['number']")
+	if err != nil || !reflect.DeepEqual(segs, []string{"items", "*", "card", "number"}) {
+		t.Errorf("unexpected segments %q, %v", segs, err)
+	}
+	for _, bad := range []string{"", "$", "card.number", "$.a..b", "$.a[0"} {
+		if _, err := parseJSONPath(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package xlog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultMaxLineLength is the longest command output line logged in full.
+const DefaultMaxLineLength = 16 * 1024
+
+// CommandOption configures CommandWriter and RunCommand.
+type CommandOption func(*commandOptions)
+
+type commandOptions struct {
+	maxLine     int
+	stdoutLevel slog.Level
+	stderrLevel slog.Level
+}
+
+// WithMaxLineLength cuts longer lines to n bytes and marks them truncated=true.
+// Defaults to DefaultMaxLineLength.
+func WithMaxLineLength(n int) CommandOption {
+	return func(o *commandOptions) {
+		o.maxLine = n
+	}
+}
+
+// WithCommandLevels sets the levels RunCommand logs stdout and stderr lines at.
+// Default to info and warn.
+func WithCommandLevels(stdout, stderr slog.Level) CommandOption {
+	return func(o *commandOptions) {
+		o.stdoutLevel, o.stderrLevel = stdout, stderr
+	}
+}
+
+func newCommandOptions(opts []CommandOption) commandOptions {
+	o := commandOptions{
+		maxLine:     DefaultMaxLineLength,
+		stdoutLevel: slog.LevelInfo,
+		stderrLevel: slog.LevelWarn,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// CommandWriter returns a writer that logs each line written to it as a record at level,
+// through the logger in ctx (see FromContext) and with stream as the "stream" attr.
+// Close logs a final line that did not end in a newline.
+//
+// It suits any line oriented output; RunCommand wires it up for an exec.Cmd.
+func CommandWriter(ctx context.Context, stream string, level slog.Level, opts ...CommandOption) io.WriteCloser {
+	o := newCommandOptions(opts)
+	logger := FromContext(ctx)
+	return &commandWriter{lineWriter{
+		max: o.maxLine,
+		emit: func(line string, truncated bool) {
+			attrs := []slog.Attr{slog.String("stream", stream)}
+			if truncated {
+				attrs = append(attrs, slog.Bool("truncated", true))
+			}
+			logger.LogAttrs(ctx, level, line, attrs...)
+		},
+	}}
+}
+
+type commandWriter struct {
+	lineWriter
+}
+
+func (w *commandWriter) Close() error {
+	w.Flush()
+	return nil
+}
+
+// RunCommand runs cmd, logging its stdout and stderr line by line with the request attrs
+// of ctx plus "cmd" (the program name, without arguments, which may be secret) and "pid".
+// Once it exits, a summary record carries the exit code and duration.
+//
+// cmd.Stdout and cmd.Stderr must not be set. The error is the one from cmd.Run.
+func RunCommand(ctx context.Context, cmd *exec.Cmd, opts ...CommandOption) error {
+	o := newCommandOptions(opts)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(cmd.Path)
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		Error(ctx, "command failed to start", err, slog.String("cmd", name))
+		return err
+	}
+	ctx = With(ctx, slog.String("cmd", name), slog.Int("pid", cmd.Process.Pid))
+
+	// The pipes must be drained before Wait closes them.
+	var wg sync.WaitGroup
+	for _, s := range []struct {
+		r      io.Reader
+		stream string
+		level  slog.Level
+	}{
+		{stdout, "stdout", o.stdoutLevel},
+		{stderr, "stderr", o.stderrLevel},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := CommandWriter(ctx, s.stream, s.level, opts...)
+			_, _ = io.Copy(w, s.r)
+			_ = w.Close()
+		}()
+	}
+	wg.Wait()
+	err = cmd.Wait()
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	attrs := []slog.Attr{
+		slog.Int("exit_code", exitCode),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, Err(err))
+	}
+	FromContext(ctx).LogAttrs(ctx, level, "command exited", attrs...)
+	return err
+}
@@ -0,0 +1,75 @@
+package xlog
+
+import (
+	"bytes"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"testing"
+)
+
+func Test_RunCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	var buf bytes.Buffer
+	ctx := RequestInfoToContext(t.Context(), RequestInfo{Tenant: "acme", RequestID: "req-1"})
+	ctx = ToContext(ctx, slog.New(NewHandler(slog.NewJSONHandler(&buf, nil), RequestInfoArgs)))
+
+	cmd := exec.Command("sh", "-c", `echo hello; echo oops >&2; printf 'no newline'; exit 3`)
+	err := RunCommand(ctx, cmd)
+	if err == nil {
+		t.Fatal("expected the exit status as error")
+	}
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 4 {
+		t.Fatalf("expected 3 output lines and a summary, got:\n%s", buf.String())
+	}
+	var msgs []string
+	for _, l := range lines {
+		if l["cmd"] != "sh" || l["pid"] == nil || l["tenant"] != "acme" || l["request_id"] != "req-1" {
+			t.Errorf("expected cmd, pid and request attrs, got %v", l)
+		}
+		msgs = append(msgs, l["msg"].(string)+"|"+l["level"].(string)+"|"+stringOr(l["stream"]))
+	}
+	for _, want := range []string{"hello|INFO|stdout", "no newline|INFO|stdout", "oops|WARN|stderr"} {
+		if !slices.Contains(msgs, want) {
+			t.Errorf("missing %q in %q", want, msgs)
+		}
+	}
+	summary := lines[3]
+	if summary["msg"] != "command exited" || summary["level"] != "ERROR" || summary["exit_code"] != float64(3) {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+}
+
+func stringOr(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func Test_CommandWriter_LongAndPartialLines(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := ToContext(t.Context(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	w := CommandWriter(ctx, "stdout", slog.LevelInfo, WithMaxLineLength(5))
+	_, _ = w.Write([]byte("abc"))
+	_, _ = w.Write([]byte("defgh"))
+	_, _ = w.Write([]byte("ijk\nshort\r\nté"))
+	_ = w.Close()
+
+	lines := decodeLines(t, &buf)
+	var got []string
+	for _, l := range lines {
+		msg := l["msg"].(string)
+		if l["truncated"] == true {
+			msg += "(truncated)"
+		}
+		got = append(got, msg)
+	}
+	want := []string{"abcde(truncated)", "short", "té"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,169 @@
+package xlog
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Key for the per-request level override.
+type ctxLevelKey struct{}
+
+// WithLevel returns ctx in which records at level or above are enabled, regardless of
+// the handler's configured level. XlogHandler and DedupHandler honor it.
+func WithLevel(ctx context.Context, level slog.Level) context.Context {
+	return context.WithValue(ctx, ctxLevelKey{}, level)
+}
+
+// LevelFromContext returns the level override set with WithLevel.
+func LevelFromContext(ctx context.Context) (slog.Level, bool) {
+	level, ok := ctx.Value(ctxLevelKey{}).(slog.Level)
+	return level, ok
+}
+
+// levelOverridden reports whether ctx forces level on.
+func levelOverridden(ctx context.Context, level slog.Level) bool {
+	floor, ok := LevelFromContext(ctx)
+	return ok && level >= floor
+}
+
+// DebugToken is the content of a signed debug escalation header.
+type DebugToken struct {
+	// Expires is required; expired tokens are ignored.
+	Expires time.Time
+	// Tenant, if set, limits the token to requests of that tenant.
+	Tenant string
+	// Level is enabled for the request, e.g. slog.LevelDebug.
+	Level slog.Level
+}
+
+// debugTokenPayload is the signed wire form of a DebugToken.
+type debugTokenPayload struct {
+	Exp    int64      `json:"exp"`
+	Tenant string     `json:"tenant,omitempty"`
+	Level  slog.Level `json:"level"`
+}
+
+var (
+	errDebugTokenMalformed = errors.New("malformed token")
+	errDebugTokenSignature = errors.New("bad signature")
+	errDebugTokenExpired   = errors.New("expired")
+	errDebugTokenTenant    = errors.New("wrong tenant")
+)
+
+// NewDebugToken signs t with key, for support tooling. The result goes in the
+// debug escalation header as is.
+func NewDebugToken(key []byte, t DebugToken) (string, error) {
+	payload, err := json.Marshal(debugTokenPayload{Exp: t.Expires.Unix(), Tenant: t.Tenant, Level: t.Level})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(signDebugToken(key, payload)), nil
+}
+
+func signDebugToken(key, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// parseDebugToken verifies token and returns its payload.
+func parseDebugToken(key []byte, token string, now time.Time) (DebugToken, error) {
+	var t DebugToken
+	var p debugTokenPayload
+	enc := base64.RawURLEncoding
+	payloadPart, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return t, errDebugTokenMalformed
+	}
+	payload, err1 := enc.DecodeString(payloadPart)
+	sig, err2 := enc.DecodeString(sigPart)
+	if err1 != nil || err2 != nil {
+		return t, errDebugTokenMalformed
+	}
+	if !hmac.Equal(sig, signDebugToken(key, payload)) {
+		return t, errDebugTokenSignature
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return t, errDebugTokenMalformed
+	}
+	t = DebugToken{Expires: time.Unix(p.Exp, 0), Tenant: p.Tenant, Level: p.Level}
+	if p.Exp == 0 || !now.Before(t.Expires) {
+		return t, errDebugTokenExpired
+	}
+	return t, nil
+}
+
+// DebugEscalationConfig configures MiddlewareDebugEscalation.
+type DebugEscalationConfig struct {
+	// Skipper skips the middleware for matching requests.
+	Skipper middleware.Skipper
+
+	// Key signs and verifies tokens. Required: MiddlewareDebugEscalation panics without it.
+	Key []byte
+	// Header carrying the token. Defaults to X-Debug-Log.
+	Header string
+
+	// Now is used to check expiry. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// MiddlewareDebugEscalation raises logging for a single request to the level carried
+// by a valid token in the debug header, see NewDebugToken. Unsigned, expired or
+// wrong-tenant tokens are ignored and the attempt is logged as a warning.
+//
+// The tenant is checked against Tenant(ctx), so install it after tenant resolution
+// and the attach middlewares.
+func MiddlewareDebugEscalation(config DebugEscalationConfig) echo.MiddlewareFunc {
+	if len(config.Key) == 0 {
+		// Tokens signed with an empty key would verify, letting anyone raise the level.
+		panic("xlog: MiddlewareDebugEscalation needs a Key")
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.Header == "" {
+		config.Header = "X-Debug-Log"
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			req := c.Request()
+			token := req.Header.Get(config.Header)
+			if token == "" {
+				return next(c)
+			}
+
+			ctx := req.Context()
+			t, err := parseDebugToken(config.Key, token, config.Now())
+			if err == nil && t.Tenant != "" && t.Tenant != Tenant(ctx) {
+				err = errDebugTokenTenant
+			}
+			if err != nil {
+				Warn(ctx, "rejected debug escalation", slog.String("reason", err.Error()))
+				return next(c)
+			}
+
+			c.SetRequest(req.WithContext(WithLevel(ctx, t.Level)))
+			Info(c.Request().Context(), "debug escalation enabled",
+				slog.String("debug_level", t.Level.String()), slog.Time("expires", t.Expires))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package xlog
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_MiddlewareDebugEscalation(t *testing.T) {
+	key := []byte("support-key")
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sign := func(tok DebugToken) string {
+		s, err := NewDebugToken(key, tok)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	valid := sign(DebugToken{Expires: now.Add(time.Hour), Tenant: "acme", Level: slog.LevelDebug})
+	forged, _ := NewDebugToken([]byte("guessed-key"), DebugToken{Expires: now.Add(time.Hour), Level: slog.LevelDebug})
+
+	cases := []struct {
+		name   string
+		token  string
+		tenant string
+		debug  bool
+	}{
+		{"valid", valid, "acme", true},
+		{"no token", "", "acme", false},
+		{"wrong tenant", valid, "globex", false},
+		{"expired", sign(DebugToken{Expires: now.Add(-time.Minute), Level: slog.LevelDebug}), "acme", false},
+		{"forged", forged, "acme", false},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		inner := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+		logger := slog.New(NewHandler(inner))
+
+		e := echo.New()
+		e.Use(MiddlewareAttachDefaultsLogger(logger))
+		e.Use(MiddlewareDebugEscalation(DebugEscalationConfig{Key: key, Now: func() time.Time { return now }}))
+		e.GET("/", func(c echo.Context) error {
+			DebugC(c, "details")
+			return c.NoContent(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/?tenantId="+tc.tenant, nil)
+		if tc.token != "" {
+			req.Header.Set("X-Debug-Log", tc.token)
+		}
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		if got := bytes.Contains(buf.Bytes(), []byte(`"msg":"details"`)); got != tc.debug {
+			t.Errorf("%s: want debug line %v, got %v\n%s", tc.name, tc.debug, got, buf.String())
+		}
+		for _, line := range bytes.Split(buf.Bytes(), []byte("\n")) {
+			// Checked on the raw line: a second level key would be hidden by json.Unmarshal.
+			if bytes.Contains(line, []byte("debug escalation enabled")) &&
+				(bytes.Count(line, []byte(`"level"`)) != 1 || !bytes.Contains(line, []byte(`"debug_level":"DEBUG"`))) {
+				t.Errorf("%s: expected the level as debug_level, got %s", tc.name, line)
+			}
+		}
+		rejected := bytes.Contains(buf.Bytes(), []byte("rejected debug escalation"))
+		if rejected != (tc.token != "" && !tc.debug) {
+			t.Errorf("%s: unexpected rejection log %v\n%s", tc.name, rejected, buf.String())
+		}
+	}
+}
+
+func Test_MiddlewareDebugEscalation_RequiresKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic without a Key, as tokens signed with no key would verify")
+		}
+	}()
+	MiddlewareDebugEscalation(DebugEscalationConfig{})
+}
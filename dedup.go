@@ -0,0 +1,390 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+)
+
+// DedupPolicy decides what happens when a key is logged more than once at the same level.
+type DedupPolicy int
+
+const (
+	// DedupLastWins keeps the most recent value, e.g. xlog.With over a middleware default.
+	DedupLastWins DedupPolicy = iota
+	// DedupFirstWins keeps the value that was bound first.
+	DedupFirstWins
+	// DedupRename keeps every value, renaming later ones to key#2, key#3, ...
+	DedupRename
+)
+
+// DedupOptions configures a DedupHandler.
+type DedupOptions struct {
+	Policy DedupPolicy
+
+	// Protected top-level keys belong to the trusted sources, the attach middlewares and
+	// the XlogHandler extractors, whose values always win whatever the Policy. Any other
+	// attempt to set a bound protected key is renamed or rejected and reported at debug
+	// level, as is an untrusted value that a trusted one displaces.
+	Protected *ProtectedKeys
+}
+
+// DedupHandler removes duplicate keys from records before they reach the wrapped handler.
+// It tracks attrs from WithAttrs (logger.With, xlog.With, attach middlewares), from the
+// record itself and from anything added by handlers in front of it, such as the
+// XlogHandler extractors:
+//
+//	slog.New(xlog.NewHandler(xlog.NewDedupHandler(jsonHandler, nil), xlog.DefaultPerRequestArgs))
+//
+// Groups are respected: a key only clashes with keys in the same group, and groups
+// with the same name are merged.
+type DedupHandler struct {
+	base    slog.Handler // the wrapped handler, as given
+	handler slog.Handler // base with attrs and groups applied
+	opts    DedupOptions
+
+	// attrs bound so far, already deduped; groups opened with WithGroup nest inside them.
+	attrs  []slog.Attr
+	groups []string
+	// bound indexes the innermost open group, where record attrs land.
+	bound map[string]slog.Kind
+	// trusted lists the protected keys whose bound value came from a trusted source.
+	trusted []string
+}
+
+func NewDedupHandler(handler slog.Handler, opts *DedupOptions) *DedupHandler {
+	h := &DedupHandler{base: handler, handler: handler}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+var (
+	_ slog.Handler   = (*DedupHandler)(nil)
+	_ trustedHandler = (*DedupHandler)(nil)
+)
+
+// Enabled also honors a per-request level override, see WithLevel.
+func (h *DedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return levelOverridden(ctx, level) || h.handler.Enabled(ctx, level)
+}
+
+// Handle only merges the record's own attrs, checking them against the bound keys. The
+// whole tree is merged again only when a record attr replaces or extends a bound one.
+func (h *DedupHandler) Handle(ctx context.Context, rec slog.Record) error {
+	return h.handleTrusted(ctx, rec, nil)
+}
+
+func (h *DedupHandler) handleTrusted(ctx context.Context, rec slog.Record, trusted []slog.Attr) error {
+	incoming := make([]slog.Attr, 0, rec.NumAttrs())
+	rec.Attrs(func(a slog.Attr) bool {
+		incoming = append(incoming, a)
+		return true
+	})
+
+	handler := h.handler
+	attrs, blocked, ok := h.mergeRecord(incoming, trusted)
+	if !ok {
+		handler = h.base
+		attrs, _, blocked = h.insert(incoming, trusted)
+	}
+	h.report(ctx, blocked)
+
+	nr := slog.NewRecord(rec.Time, rec.Level, rec.Message, rec.PC)
+	nr.AddAttrs(attrs...)
+	return handler.Handle(ctx, nr)
+}
+
+// WithAttrs dedupes attrs against the bound ones here, once, and passes the result on,
+// so that the wrapped handler only ever sees deduped attrs.
+func (h *DedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.bind(attrs, false)
+}
+
+func (h *DedupHandler) withTrustedAttrs(attrs []slog.Attr) slog.Handler {
+	return h.bind(attrs, true)
+}
+
+func (h *DedupHandler) bind(attrs []slog.Attr, trust bool) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	m := merger{DedupOptions: h.opts, trust: trust, trusted: h.trusted}
+	tree := m.insertAt(h.attrs, h.groups, attrs, true)
+	h.report(context.Background(), m.blocked)
+
+	nh := *h
+	nh.attrs, nh.trusted = tree, m.trusted
+	level := innermost(tree, h.groups)
+	if m.replaced {
+		nh.handler = bindTree(h.base, tree, h.groups)
+	} else {
+		// Only appended, so the wrapped handler just needs the new attrs.
+		nh.handler = h.handler.WithAttrs(level[len(innermost(h.attrs, h.groups)):])
+	}
+	nh.bound = indexKinds(level)
+	return &nh
+}
+
+func (h *DedupHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	nh := *h
+	nh.groups = append(slices.Clip(h.groups), name)
+	level := innermost(h.attrs, nh.groups)
+	if level != nil {
+		// A group of that name is already bound; it is continued rather than repeated.
+		nh.handler = bindTree(h.base, h.attrs, nh.groups)
+	} else {
+		nh.handler = h.handler.WithGroup(name)
+	}
+	nh.bound = indexKinds(level)
+	return &nh
+}
+
+// insert merges attrs, then trusted, into the innermost open group and returns the new
+// attr tree and trusted keys, plus diagnostics for blocked protected keys. h.attrs is
+// never modified.
+func (h *DedupHandler) insert(attrs, trusted []slog.Attr) ([]slog.Attr, []string, []slog.Record) {
+	m := merger{DedupOptions: h.opts, trusted: h.trusted}
+	tree := m.insertAt(h.attrs, h.groups, attrs, true)
+	if len(trusted) > 0 {
+		m.trust = true
+		tree = m.insertAt(tree, h.groups, trusted, true)
+	}
+	return tree, m.trusted, m.blocked
+}
+
+// mergeRecord dedupes the attrs of a record, then the trusted ones, among themselves and
+// against the bound keys, for the handler that has the bound attrs applied. It reports
+// false if that takes changing a bound attr, which only insert can do.
+func (h *DedupHandler) mergeRecord(attrs, trusted []slog.Attr) ([]slog.Attr, []slog.Record, bool) {
+	m := merger{DedupOptions: h.opts, trusted: h.trusted}
+	top := len(h.groups) == 0
+	out := make([]slog.Attr, 0, len(attrs)+len(trusted))
+	for i := range len(attrs) + len(trusted) {
+		a := slog.Attr{}
+		if m.trust = i >= len(attrs); m.trust {
+			a = trusted[i-len(attrs)]
+		} else {
+			a = attrs[i]
+		}
+		if kind, ok := h.bound[a.Key]; ok {
+			a.Value = a.Value.Resolve()
+			if top && m.Protected.protects(a.Key) {
+				if m.trust {
+					return nil, nil, false
+				}
+				na, kept := m.Protected.apply(a)
+				m.blocked = append(m.blocked, m.Protected.diagnostic(a.Key, na, kept))
+				if !kept {
+					continue
+				}
+				a = na
+			} else if kind == slog.KindGroup && a.Value.Kind() == slog.KindGroup {
+				return nil, nil, false
+			} else {
+				switch m.Policy {
+				case DedupFirstWins:
+					continue
+				case DedupRename:
+					a.Key = renameKey(out, h.bound, a.Key)
+				default:
+					return nil, nil, false
+				}
+			}
+		}
+		out = m.merge(out, a, top)
+	}
+	// Renames and inline groups may still have landed on a bound key.
+	for _, a := range out {
+		if _, ok := h.bound[a.Key]; ok {
+			return nil, nil, false
+		}
+	}
+	return out, m.blocked, true
+}
+
+// report passes diagnostics for blocked keys to the wrapped handler.
+func (h *DedupHandler) report(ctx context.Context, blocked []slog.Record) {
+	for _, r := range blocked {
+		if h.base.Enabled(ctx, r.Level) {
+			_ = h.base.Handle(ctx, r)
+		}
+	}
+}
+
+// innermost returns the attrs of the group at path in tree, nil if it is not there.
+func innermost(tree []slog.Attr, path []string) []slog.Attr {
+	for _, name := range path {
+		i := slices.IndexFunc(tree, func(a slog.Attr) bool {
+			return a.Key == name && a.Value.Kind() == slog.KindGroup
+		})
+		if i < 0 {
+			return nil
+		}
+		tree = tree[i].Value.Group()
+	}
+	return tree
+}
+
+// bindTree applies tree to h, opening the groups of path with WithGroup.
+func bindTree(h slog.Handler, tree []slog.Attr, path []string) slog.Handler {
+	for _, name := range path {
+		i := slices.IndexFunc(tree, func(a slog.Attr) bool {
+			return a.Key == name && a.Value.Kind() == slog.KindGroup
+		})
+		var children []slog.Attr
+		if i >= 0 {
+			children = tree[i].Value.Group()
+			tree = slices.Delete(slices.Clone(tree), i, i+1)
+		}
+		h = h.WithAttrs(tree).WithGroup(name)
+		tree = children
+	}
+	return h.WithAttrs(tree)
+}
+
+func indexKinds(level []slog.Attr) map[string]slog.Kind {
+	if len(level) == 0 {
+		return nil
+	}
+	m := make(map[string]slog.Kind, len(level))
+	for _, a := range level {
+		m[a.Key] = a.Value.Kind()
+	}
+	return m
+}
+
+// merger performs one insert, collecting diagnostics along the way.
+type merger struct {
+	DedupOptions
+	blocked []slog.Record
+	// replaced is set once an existing attr is changed rather than appended to.
+	replaced bool
+
+	// trust marks the attrs being merged as coming from a trusted source, and trusted
+	// lists the protected keys holding such a value. It is copied before being changed.
+	trust   bool
+	trusted []string
+}
+
+func (m *merger) insertAt(level []slog.Attr, path []string, attrs []slog.Attr, top bool) []slog.Attr {
+	out := slices.Clone(level)
+	if len(path) == 0 {
+		for _, a := range attrs {
+			out = m.merge(out, a, top)
+		}
+		return out
+	}
+
+	i := slices.IndexFunc(out, func(a slog.Attr) bool {
+		return a.Key == path[0] && a.Value.Kind() == slog.KindGroup
+	})
+	if i < 0 {
+		out = append(out, slog.Attr{Key: path[0], Value: slog.GroupValue()})
+		i = len(out) - 1
+	}
+	children := m.insertAt(out[i].Value.Group(), path[1:], attrs, false)
+	out[i] = slog.Attr{Key: path[0], Value: slog.GroupValue(children...)}
+	return out
+}
+
+// merge adds a to level (which it may modify) according to the policy.
+// top reports whether level is the root of the record, where protected keys apply.
+func (m *merger) merge(level []slog.Attr, a slog.Attr, top bool) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return level
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key == "" {
+			// Inline group, its attrs belong to this level.
+			for _, ga := range a.Value.Group() {
+				level = m.merge(level, ga, top)
+			}
+			return level
+		}
+		if i := indexKey(level, a.Key); i >= 0 && level[i].Value.Kind() == slog.KindGroup {
+			children := slices.Clone(level[i].Value.Group())
+			for _, ga := range a.Value.Group() {
+				children = m.merge(children, ga, false)
+			}
+			level[i] = slog.Attr{Key: a.Key, Value: slog.GroupValue(children...)}
+			m.replaced = true
+			return level
+		}
+	}
+
+	protected := top && m.Protected.protects(a.Key)
+	i := indexKey(level, a.Key)
+	if i < 0 {
+		if protected && m.trust {
+			m.markTrusted(a.Key)
+		}
+		return append(level, a)
+	}
+	if protected {
+		return m.protect(level, i, a)
+	}
+	switch m.Policy {
+	case DedupFirstWins:
+		return level
+	case DedupRename:
+		a.Key = renameKey(level, nil, a.Key)
+		return append(level, a)
+	default:
+		level[i] = a
+		m.replaced = true
+		return level
+	}
+}
+
+// protect resolves setting a protected key already held by level[i]. A trusted value
+// takes the key, moving an untrusted one aside; any other value is moved aside itself.
+func (m *merger) protect(level []slog.Attr, i int, a slog.Attr) []slog.Attr {
+	if m.trust {
+		old := level[i]
+		level[i] = a
+		m.replaced = true
+		if slices.Contains(m.trusted, a.Key) {
+			return level
+		}
+		m.markTrusted(a.Key)
+		a = old
+	}
+	na, kept := m.Protected.apply(a)
+	m.blocked = append(m.blocked, m.Protected.diagnostic(a.Key, na, kept))
+	if !kept {
+		return level
+	}
+	trust := m.trust
+	m.trust = false
+	level = m.merge(level, na, true)
+	m.trust = trust
+	return level
+}
+
+func (m *merger) markTrusted(key string) {
+	if !slices.Contains(m.trusted, key) {
+		m.trusted = append(slices.Clip(m.trusted), key)
+	}
+}
+
+func indexKey(level []slog.Attr, key string) int {
+	return slices.IndexFunc(level, func(a slog.Attr) bool { return a.Key == key })
+}
+
+// renameKey returns the first of key#2, key#3, ... that is free in level and bound.
+func renameKey(level []slog.Attr, bound map[string]slog.Kind, key string) string {
+	for n := 2; ; n++ {
+		k := key + "#" + strconv.Itoa(n)
+		if _, ok := bound[k]; !ok && indexKey(level, k) < 0 {
+			return k
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package xlog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+)
+
+// DedupHandler with many bound attrs: the cost of a log call should not grow with them
+func BenchmarkDedupHandler(b *testing.B) {
+	for _, n := range []int{4, 64} {
+		b.Run(strconv.Itoa(n)+"_bound", func(b *testing.B) {
+			base := slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
+				Level: slog.LevelInfo,
+			})
+			logger := slog.New(NewDedupHandler(base, &DedupOptions{Protected: &DefaultProtectedKeys}))
+			for i := range n {
+				logger = logger.With("key"+strconv.Itoa(i), i)
+			}
+			ctx := context.Background()
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				logger.InfoContext(ctx, "processing request", "order_id", 42, "step", "charge")
+			}
+		})
+	}
+}
+
+// DedupHandler where every record overrides a bound key, forcing the full merge
+func BenchmarkDedupHandlerOverride(b *testing.B) {
+	base := slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})
+	logger := slog.New(NewDedupHandler(base, nil)).With("tenant", "test-tenant", "order_id", 1)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		logger.InfoContext(ctx, "processing request", "order_id", 42)
+	}
+}
@@ -0,0 +1,151 @@
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_DedupHandler_Policies(t *testing.T) {
+	cases := []struct {
+		policy DedupPolicy
+		want   map[string]any
+	}{
+		{DedupLastWins, map[string]any{"tenant": "extracted"}},
+		{DedupFirstWins, map[string]any{"tenant": "bound"}},
+		{DedupRename, map[string]any{"tenant": "bound", "tenant#2": "with", "tenant#3": "record", "tenant#4": "extracted"}},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		dedup := NewDedupHandler(slog.NewJSONHandler(&buf, nil), &DedupOptions{Policy: tc.policy})
+		extract := func(context.Context) []slog.Attr {
+			return []slog.Attr{slog.String("tenant", "extracted")}
+		}
+		logger := slog.New(NewHandler(dedup, extract)).With("tenant", "bound")
+
+		ctx := ToContext(context.Background(), logger)
+		ctx = With(ctx, "tenant", "with")
+		Info(ctx, "hello", "tenant", "record")
+
+		var rec map[string]any
+		if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &rec); err != nil {
+			t.Fatalf("policy %d: unmarshal: %v\n%s", tc.policy, err, buf.String())
+		}
+		for k, v := range tc.want {
+			if rec[k] != v {
+				t.Errorf("policy %d: want %s=%v, got %v (%s)", tc.policy, k, v, rec[k], buf.String())
+			}
+		}
+		if n := bytes.Count(buf.Bytes(), []byte(`"tenant"`)); n != 1 {
+			t.Errorf("policy %d: expected one plain tenant key, got %d: %s", tc.policy, n, buf.String())
+		}
+	}
+}
+
+func Test_DedupHandler_GroupAware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewDedupHandler(slog.NewJSONHandler(&buf, nil), nil))
+
+	logger.With("id", 1, slog.Group("http", "method", "GET")).
+		WithGroup("http").With("method", "POST").
+		Info("hello", "id", 2, "status", 200)
+
+	want := `"id":1,"http":{"method":"POST","id":2,"status":200}`
+	if !bytes.Contains(buf.Bytes(), []byte(want)) {
+		t.Errorf("want %s in %s", want, buf.String())
+	}
+}
+
+func Test_ProtectedKeys_HandlerAndContext(t *testing.T) {
+	var buf bytes.Buffer
+	inner := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	logger := slog.New(NewDedupHandler(inner, &DedupOptions{Protected: &DefaultProtectedKeys})).
+		With("tenant", "acme", "request_id", "req-1")
+
+	// Handler side: record attrs cannot replace the bound tenant.
+	logger.Info("handler", "tenant", "evil")
+
+	// Context side: With renames protected keys before they reach the logger.
+	ctx := Protect(ToContext(context.Background(), slog.New(inner)), ProtectedKeys{
+		Keys:   []string{"tenant"},
+		Action: ProtectReject,
+	})
+	ctx = With(ctx, "tenant", "evil", "order", 1)
+	Info(ctx, "context")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 4 {
+		t.Fatalf("expected 2 diagnostics and 2 records, got %d\n%s", len(lines), buf.String())
+	}
+	recs := make([]map[string]any, len(lines))
+	for i, l := range lines {
+		if err := json.Unmarshal(l, &recs[i]); err != nil {
+			t.Fatalf("unmarshal: %v\n%s", err, l)
+		}
+	}
+
+	if recs[0]["action"] != "renamed" || recs[0]["level"] != "DEBUG" {
+		t.Errorf("expected a debug rename diagnostic, got %v", recs[0])
+	}
+	if recs[1]["tenant"] != "acme" || recs[1]["user.tenant"] != "evil" {
+		t.Errorf("expected tenant=acme and user.tenant=evil, got %v", recs[1])
+	}
+	if recs[2]["action"] != "rejected" {
+		t.Errorf("expected a reject diagnostic, got %v", recs[2])
+	}
+	if _, ok := recs[3]["tenant"]; ok || recs[3]["order"] != float64(1) {
+		t.Errorf("expected tenant to be dropped and order kept, got %v", recs[3])
+	}
+}
+
+func Test_DedupHandler_RecordAgainstBound(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewDedupHandler(slog.NewJSONHandler(&buf, nil), &DedupOptions{Policy: DedupRename})).
+		With("id", 1, "id", 2).WithGroup("g").With("k", "v")
+
+	logger.Info("hello", "k", "x", "other", true)
+	logger.Info("again", "id", 3)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %s", buf.String())
+	}
+	if want := `"id":1,"id#2":2,"g":{"k":"v","k#2":"x","other":true}`; !bytes.Contains(lines[0], []byte(want)) {
+		t.Errorf("want %s in %s", want, lines[0])
+	}
+	if want := `"id":1,"id#2":2,"g":{"k":"v","id":3}`; !bytes.Contains(lines[1], []byte(want)) {
+		t.Errorf("want %s in %s", want, lines[1])
+	}
+}
+
+func Test_ProtectedKeys_TrustedSourceWins(t *testing.T) {
+	var buf bytes.Buffer
+	inner := slog.NewJSONHandler(&buf, nil)
+	logger := slog.New(NewHandler(NewDedupHandler(inner, &DedupOptions{Protected: &DefaultProtectedKeys}), RequestInfoArgs))
+
+	e := echo.New()
+	e.Use(MiddlewareAttachDefaultsCtx(logger))
+	e.GET("/", func(c echo.Context) error {
+		ctx := ToContext(c.Request().Context(), logger)
+		ctx = With(ctx, "tenant", "evil")
+		Info(ctx, "handler")
+		return c.NoContent(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/?tenantId=acme", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := buf.String()
+	if !strings.Contains(line, `"tenant":"acme"`) || !strings.Contains(line, `"user.tenant":"evil"`) {
+		t.Errorf("expected the extracted tenant to keep the key, got %s", line)
+	}
+	if strings.Count(line, `"tenant"`) != 1 {
+		t.Errorf("expected a single tenant key, got %s", line)
+	}
+}
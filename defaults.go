@@ -15,14 +15,19 @@ type ctxKey string
 const (
 	CtxTenantKey  ctxKey = "tenant"
 	CtxReqIDKey   ctxKey = "request_id"
-	CtxUserKey    ctxKey = "user" // optional: add whatever you like
+	CtxUserKey    ctxKey = "user" // set by MiddlewareUser
 	CtxMethodKey  ctxKey = "method"
 	CtxURIPathKey ctxKey = "path"
 	CtxURIKey     ctxKey = "uri"
 )
 
-// Example on how to pull individual args from context
+// Example on how to pull individual args from context.
+// Uses the RequestInfo when present, otherwise the individual ctxKey values.
 func DefaultPerRequestArgs(ctx context.Context) []slog.Attr {
+	if attrs := RequestInfoArgs(ctx); attrs != nil {
+		return attrs
+	}
+
 	// Add global context attrs to log here.
 	r := []slog.Attr{}
 	if v := ctx.Value(CtxTenantKey); v != nil {
@@ -6,13 +6,11 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-// With adds one or more key–value pairs to the logger stored in the context,
-// returning a *new* echo.Context whose request.Context carries that enriched logger.
-// Use it when you want subsequent log calls to automatically include those attributes.
+// WithC adds one or more key–value pairs to the logger carried in the request context
+// (see With, including its dedupe and protected keys), storing the new context back on
+// the request. Use it when you want subsequent log calls to automatically include those attributes.
 func WithC(c echo.Context, attrs ...any) echo.Context {
-	ctx := c.Request().Context()
-	logger := FromContext(ctx).With(attrs...)
-	ctx = ToContext(ctx, logger)
+	ctx := With(c.Request().Context(), attrs...)
 
 	// Attach the new context back to the request so later handlers/middleware see it.
 	req := c.Request().WithContext(ctx)
@@ -25,21 +23,17 @@ func WithC(c echo.Context, attrs ...any) echo.Context {
 // ---------------------------------------------------------------------
 
 func DebugC(c echo.Context, msg string, args ...any) {
-	ctx := c.Request().Context()
-	FromContext(ctx).DebugContext(ctx, msg, args...)
+	logDepth(c.Request().Context(), 1, slog.LevelDebug, msg, args...)
 }
 
 func InfoC(c echo.Context, msg string, args ...any) {
-	ctx := c.Request().Context()
-	FromContext(ctx).InfoContext(ctx, msg, args...)
+	logDepth(c.Request().Context(), 1, slog.LevelInfo, msg, args...)
 }
 
 func WarnC(c echo.Context, msg string, args ...any) {
-	ctx := c.Request().Context()
-	FromContext(ctx).WarnContext(ctx, msg, args...)
+	logDepth(c.Request().Context(), 1, slog.LevelWarn, msg, args...)
 }
 
 func ErrorC(c echo.Context, msg string, err error, args ...any) {
-	ctx := c.Request().Context()
-	FromContext(ctx).ErrorContext(ctx, msg, append(args, slog.String("error", err.Error()))...)
+	logDepth(c.Request().Context(), 1, slog.LevelError, msg, append(args, Err(err))...)
 }
@@ -0,0 +1,291 @@
+package xlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// Levels above slog.LevelError for echo's Fatal and Panic.
+const (
+	LevelPanic = slog.LevelError + 4
+	LevelFatal = slog.LevelError + 8
+)
+
+// EchoLogger is an echo.Logger that sends echo's own logging (e.Logger, c.Logger() and
+// anything writing to Output) through slog, so it gets the same format, sinks and
+// redaction as everything else:
+//
+//	e.Logger = xlog.NewEchoLogger(logger)
+//	e.Use(xlog.MiddlewareEchoLogger(e.Logger.(*xlog.EchoLogger)))
+//
+// Levels map to their slog counterparts, Print logs at info, and Fatal and Panic log at
+// LevelFatal and LevelPanic before exiting or panicking. The j variants log each key as
+// an attr, using "message" or "msg" as the message. The prefix is logged as "prefix".
+// SetHeader is a no-op: the format is up to the slog handler.
+//
+// echo.New builds e.StdLogger, which becomes the http.Server's error log, from the
+// logger it starts with, so point it here as well:
+//
+//	e.StdLogger = xlog.NewStdLogger(logger, slog.LevelError)
+type EchoLogger struct {
+	shared *echoLoggerState
+
+	// ctx returns the context of the request being served, nil outside a request.
+	ctx func() context.Context
+}
+
+// echoLoggerState is shared by an EchoLogger and its per-request copies, so that
+// settings made on e.Logger apply everywhere.
+type echoLoggerState struct {
+	level atomic.Uint32 // log.Lvl
+
+	mu     sync.RWMutex
+	logger *slog.Logger
+	prefix string
+	output io.Writer
+}
+
+var _ echo.Logger = (*EchoLogger)(nil)
+
+// NewEchoLogger returns an EchoLogger writing to logger, with every gommon level enabled
+// so that filtering is left to the slog handler.
+func NewEchoLogger(logger *slog.Logger) *EchoLogger {
+	l := &EchoLogger{shared: &echoLoggerState{logger: logger}}
+	l.shared.level.Store(uint32(log.DEBUG))
+	l.shared.output = &lineWriter{max: DefaultMaxLineLength, emit: func(line string, truncated bool) {
+		if truncated {
+			l.log(2, slog.LevelInfo, line, slog.Bool("truncated", true))
+			return
+		}
+		l.log(2, slog.LevelInfo, line)
+	}}
+	return l
+}
+
+// MiddlewareEchoLogger replaces c.Logger() with a copy of l that logs through the
+// request logger (see FromContext) with the request context, so records carry the
+// request attrs and honor per-request levels.
+func MiddlewareEchoLogger(l *EchoLogger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.SetLogger(&EchoLogger{
+				shared: l.shared,
+				ctx:    func() context.Context { return c.Request().Context() },
+			})
+			return next(c)
+		}
+	}
+}
+
+// Output returns the writer set with SetOutput, by default a writer that logs each line
+// written to it at info level, cut to DefaultMaxLineLength. Echo prints its banner to it.
+func (l *EchoLogger) Output() io.Writer {
+	l.shared.mu.RLock()
+	defer l.shared.mu.RUnlock()
+	return l.shared.output
+}
+
+// SetOutput redirects the logger to a JSON handler writing to w, like gommon would.
+func (l *EchoLogger) SetOutput(w io.Writer) {
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	l.shared.output = w
+	l.shared.logger = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func (l *EchoLogger) Prefix() string {
+	l.shared.mu.RLock()
+	defer l.shared.mu.RUnlock()
+	return l.shared.prefix
+}
+
+func (l *EchoLogger) SetPrefix(p string) {
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	l.shared.prefix = p
+}
+
+func (l *EchoLogger) Level() log.Lvl {
+	return log.Lvl(l.shared.level.Load())
+}
+
+// SetLevel filters on top of the slog handler's own level.
+func (l *EchoLogger) SetLevel(v log.Lvl) {
+	l.shared.level.Store(uint32(v))
+}
+
+func (l *EchoLogger) SetHeader(h string) {}
+
+func (l *EchoLogger) Print(i ...any) {
+	l.log(1, slog.LevelInfo, fmt.Sprint(i...))
+}
+
+func (l *EchoLogger) Printf(format string, a ...any) {
+	l.log(1, slog.LevelInfo, fmt.Sprintf(format, a...))
+}
+
+func (l *EchoLogger) Printj(j log.JSON) {
+	l.logJSON(slog.LevelInfo, j)
+}
+
+func (l *EchoLogger) Debug(i ...any) {
+	if l.enabled(log.DEBUG) {
+		l.log(1, slog.LevelDebug, fmt.Sprint(i...))
+	}
+}
+
+func (l *EchoLogger) Debugf(format string, a ...any) {
+	if l.enabled(log.DEBUG) {
+		l.log(1, slog.LevelDebug, fmt.Sprintf(format, a...))
+	}
+}
+
+func (l *EchoLogger) Debugj(j log.JSON) {
+	if l.enabled(log.DEBUG) {
+		l.logJSON(slog.LevelDebug, j)
+	}
+}
+
+func (l *EchoLogger) Info(i ...any) {
+	if l.enabled(log.INFO) {
+		l.log(1, slog.LevelInfo, fmt.Sprint(i...))
+	}
+}
+
+func (l *EchoLogger) Infof(format string, a ...any) {
+	if l.enabled(log.INFO) {
+		l.log(1, slog.LevelInfo, fmt.Sprintf(format, a...))
+	}
+}
+
+func (l *EchoLogger) Infoj(j log.JSON) {
+	if l.enabled(log.INFO) {
+		l.logJSON(slog.LevelInfo, j)
+	}
+}
+
+func (l *EchoLogger) Warn(i ...any) {
+	if l.enabled(log.WARN) {
+		l.log(1, slog.LevelWarn, fmt.Sprint(i...))
+	}
+}
+
+func (l *EchoLogger) Warnf(format string, a ...any) {
+	if l.enabled(log.WARN) {
+		l.log(1, slog.LevelWarn, fmt.Sprintf(format, a...))
+	}
+}
+
+func (l *EchoLogger) Warnj(j log.JSON) {
+	if l.enabled(log.WARN) {
+		l.logJSON(slog.LevelWarn, j)
+	}
+}
+
+func (l *EchoLogger) Error(i ...any) {
+	if l.enabled(log.ERROR) {
+		l.log(1, slog.LevelError, fmt.Sprint(i...))
+	}
+}
+
+func (l *EchoLogger) Errorf(format string, a ...any) {
+	if l.enabled(log.ERROR) {
+		l.log(1, slog.LevelError, fmt.Sprintf(format, a...))
+	}
+}
+
+func (l *EchoLogger) Errorj(j log.JSON) {
+	if l.enabled(log.ERROR) {
+		l.logJSON(slog.LevelError, j)
+	}
+}
+
+func (l *EchoLogger) Fatal(i ...any) {
+	l.log(1, LevelFatal, fmt.Sprint(i...))
+	os.Exit(1)
+}
+
+func (l *EchoLogger) Fatalf(format string, a ...any) {
+	l.log(1, LevelFatal, fmt.Sprintf(format, a...))
+	os.Exit(1)
+}
+
+func (l *EchoLogger) Fatalj(j log.JSON) {
+	l.logJSON(LevelFatal, j)
+	os.Exit(1)
+}
+
+func (l *EchoLogger) Panic(i ...any) {
+	msg := fmt.Sprint(i...)
+	l.log(1, LevelPanic, msg)
+	panic(msg)
+}
+
+func (l *EchoLogger) Panicf(format string, a ...any) {
+	msg := fmt.Sprintf(format, a...)
+	l.log(1, LevelPanic, msg)
+	panic(msg)
+}
+
+func (l *EchoLogger) Panicj(j log.JSON) {
+	l.logJSON(LevelPanic, j)
+	panic(j)
+}
+
+// enabled applies the gommon level. OFF silences everything but Print, Fatal and Panic.
+func (l *EchoLogger) enabled(v log.Lvl) bool {
+	return v >= l.Level()
+}
+
+// logJSON logs j with its keys as attrs, in sorted order.
+func (l *EchoLogger) logJSON(level slog.Level, j log.JSON) {
+	var msg string
+	attrs := make([]slog.Attr, 0, len(j))
+	for _, k := range slices.Sorted(maps.Keys(j)) {
+		if s, ok := j[k].(string); ok && msg == "" && (k == "message" || k == "msg") {
+			msg = s
+			continue
+		}
+		attrs = append(attrs, slog.Any(k, j[k]))
+	}
+	l.log(2, level, msg, attrs...)
+}
+
+// log emits a record whose source is depth frames above log.
+func (l *EchoLogger) log(depth int, level slog.Level, msg string, attrs ...slog.Attr) {
+	ctx := context.Background()
+	if l.ctx != nil {
+		ctx = l.ctx()
+	}
+
+	l.shared.mu.RLock()
+	logger, prefix := l.shared.logger, l.shared.prefix
+	l.shared.mu.RUnlock()
+	if l.ctx != nil && hasContextLogger(ctx) {
+		logger = FromContext(ctx)
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(depth+2, pcs[:]) // skip runtime.Callers and log
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	if prefix != "" {
+		r.AddAttrs(slog.String("prefix", prefix))
+	}
+	r.AddAttrs(attrs...)
+	_ = logger.Handler().Handle(ctx, r)
+}
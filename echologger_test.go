@@ -0,0 +1,91 @@
+package xlog
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+func Test_EchoLogger_LevelsAndJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewEchoLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	l.SetPrefix("echo")
+	l.SetLevel(log.WARN)
+
+	l.Info("hidden")
+	l.Warnf("disk at %d%%", 91)
+	l.Errorj(log.JSON{"message": "upstream failed", "code": 502})
+	l.Print("always")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got:\n%s", buf.String())
+	}
+	if lines[0]["level"] != "WARN" || lines[0]["msg"] != "disk at 91%" || lines[0]["prefix"] != "echo" {
+		t.Errorf("unexpected warn line: %v", lines[0])
+	}
+	if lines[1]["level"] != "ERROR" || lines[1]["msg"] != "upstream failed" || lines[1]["code"] != float64(502) {
+		t.Errorf("unexpected j line: %v", lines[1])
+	}
+	if lines[2]["level"] != "INFO" || lines[2]["msg"] != "always" {
+		t.Errorf("unexpected print line: %v", lines[2])
+	}
+}
+
+func Test_EchoLogger_OutputAndRequest(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	l := NewEchoLogger(logger)
+
+	_, _ = l.Output().Write([]byte("http: TLS handshake"))
+	_, _ = l.Output().Write([]byte(" error\npartial"))
+
+	e := echo.New()
+	e.Logger = l
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.Use(MiddlewareEchoLogger(l))
+	e.GET("/", func(c echo.Context) error {
+		c.Logger().Warn("from handler")
+		return c.NoContent(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?tenantId=acme", nil))
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 || lines[0]["msg"] != "http: TLS handshake error" {
+		t.Fatalf("expected the complete output line and the handler line, got:\n%s", buf.String())
+	}
+	if lines[1]["msg"] != "from handler" || lines[1]["tenant"] != "acme" ||
+		lines[1]["request_id"] != rec.Header().Get(echo.HeaderXRequestID) {
+		t.Errorf("expected the handler line to carry the request attrs, got %v", lines[1])
+	}
+
+	var out bytes.Buffer
+	l.SetOutput(&out)
+	l.Error("redirected")
+	if !strings.Contains(out.String(), `"msg":"redirected"`) {
+		t.Errorf("expected SetOutput to redirect, got %q", out.String())
+	}
+	if l.Output() != &out {
+		t.Error("expected Output to return the writer set with SetOutput")
+	}
+}
+
+func Test_EchoLogger_OutputCapsLines(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewEchoLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	_, _ = l.Output().Write(bytes.Repeat([]byte("x"), DefaultMaxLineLength+1))
+	_, _ = l.Output().Write(bytes.Repeat([]byte("x"), DefaultMaxLineLength))
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 1 || lines[0]["truncated"] != true || len(lines[0]["msg"].(string)) != DefaultMaxLineLength {
+		t.Errorf("expected one truncated line for a stream without newlines, got %d lines", len(lines))
+	}
+}
@@ -0,0 +1,180 @@
+package xlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+)
+
+// Key for the Wrap stack capture toggle.
+type ctxErrorStacksKey struct{}
+
+// WithErrorStacks returns a context in which Wrap captures the caller's stack.
+// Stacks are off by default since capturing one costs about a microsecond.
+func WithErrorStacks(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, ctxErrorStacksKey{}, enabled)
+}
+
+func errorStacksEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(ctxErrorStacksKey{}).(bool)
+	return enabled
+}
+
+// wrapError annotates an error with a message, attrs and optionally a stack.
+// It is transparent in the logged chain; its attrs and stack are lifted into the error group.
+type wrapError struct {
+	msg   string
+	err   error
+	attrs []slog.Attr
+	pcs   []uintptr
+}
+
+func (e *wrapError) Error() string {
+	if e.msg == "" {
+		return e.err.Error()
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *wrapError) Unwrap() error {
+	return e.err
+}
+
+// Wrap annotates err with msg and slog-style attrs that are emitted wherever the error
+// is finally logged with Error, ErrorC or Err. If stacks are enabled in ctx (see
+// WithErrorStacks) the caller's stack is captured too. Wrap returns nil for a nil err.
+func Wrap(ctx context.Context, err error, msg string, attrs ...any) error {
+	if err == nil {
+		return nil
+	}
+	we := &wrapError{msg: msg, err: err, attrs: argsToAttrs(attrs)}
+	if errorStacksEnabled(ctx) {
+		we.pcs = callers(1, 32)
+	}
+	return we
+}
+
+// Err returns err as a structured "error" attr: a group with the message, the concrete
+// type, the chain of wrapped errors (including errors.Join branches) and anything
+// attached with Wrap. A nil err is logged as null instead of panicking.
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Any("error", nil)
+	}
+	return slog.Any("error", errorValue{err})
+}
+
+// errorValue defers building the group until a handler actually resolves it.
+type errorValue struct {
+	err error
+}
+
+func (v errorValue) LogValue() slog.Value {
+	err := v.err
+	attrs := []slog.Attr{
+		slog.String("msg", err.Error()),
+		slog.String("type", errorType(err)),
+	}
+
+	var extra []slog.Attr
+	var pcs []uintptr
+	for we := range wrapErrors(err) {
+		extra = append(extra, we.attrs...)
+		if pcs == nil {
+			pcs = we.pcs
+		}
+	}
+
+	base := unwrapTransparent(err)
+	if multi, ok := base.(interface{ Unwrap() []error }); ok {
+		attrs = append(attrs, slog.Any("join", joinBranches(multi)))
+	} else if chain := errorChain(errors.Unwrap(base)); len(chain) > 0 {
+		attrs = append(attrs, slog.Any("chain", chain))
+	}
+	attrs = append(attrs, extra...)
+	if pcs != nil {
+		attrs = append(attrs, stackAttr(pcs))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// chainLink is one error in a logged chain. Join holds the branches of a multi-error.
+type chainLink struct {
+	Msg  string        `json:"msg"`
+	Type string        `json:"type"`
+	Join [][]chainLink `json:"join,omitempty"`
+}
+
+// errorChain walks err and everything it wraps, following errors.Join style
+// Unwrap() []error into separate branches.
+func errorChain(err error) []chainLink {
+	var chain []chainLink
+	for err != nil {
+		if we, ok := err.(*wrapError); ok {
+			err = we.err
+			continue
+		}
+		link := chainLink{Msg: err.Error(), Type: errorType(err)}
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			link.Join = joinBranches(multi)
+			chain = append(chain, link)
+			break
+		}
+		chain = append(chain, link)
+		err = errors.Unwrap(err)
+	}
+	return chain
+}
+
+func joinBranches(multi interface{ Unwrap() []error }) [][]chainLink {
+	var branches [][]chainLink
+	for _, branch := range multi.Unwrap() {
+		if branch != nil {
+			branches = append(branches, errorChain(branch))
+		}
+	}
+	return branches
+}
+
+// wrapErrors yields every wrapError in err's tree, outermost first.
+func wrapErrors(err error) iter.Seq[*wrapError] {
+	return func(yield func(*wrapError) bool) {
+		var walk func(error) bool
+		walk = func(err error) bool {
+			for err != nil {
+				if we, ok := err.(*wrapError); ok && !yield(we) {
+					return false
+				}
+				if multi, ok := err.(interface{ Unwrap() []error }); ok {
+					for _, branch := range multi.Unwrap() {
+						if !walk(branch) {
+							return false
+						}
+					}
+					return true
+				}
+				err = errors.Unwrap(err)
+			}
+			return true
+		}
+		walk(err)
+	}
+}
+
+// unwrapTransparent skips the wrapErrors at the top of err.
+func unwrapTransparent(err error) error {
+	for {
+		we, ok := err.(*wrapError)
+		if !ok {
+			return err
+		}
+		err = we.err
+	}
+}
+
+// errorType names the concrete type of err, looking through Wrap annotations.
+func errorType(err error) string {
+	return fmt.Sprintf("%T", unwrapTransparent(err))
+}
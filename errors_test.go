@@ -0,0 +1,63 @@
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"testing"
+)
+
+func Test_Error_StructuredGroup(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := ToContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	ctx = WithErrorStacks(ctx, true)
+
+	cause := fmt.Errorf("open config: %w", fs.ErrNotExist)
+	err := Wrap(ctx, errors.Join(cause, errors.New("second")), "load settings", "file", "app.yaml")
+	Error(ctx, "startup failed", fmt.Errorf("boot: %w", err))
+
+	var rec struct {
+		Error struct {
+			Msg   string      `json:"msg"`
+			Type  string      `json:"type"`
+			File  string      `json:"file"`
+			Chain []chainLink `json:"chain"`
+			Stack []Frame     `json:"stack"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &rec); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, buf.String())
+	}
+
+	got := rec.Error
+	if got.Msg != "boot: load settings: open config: file does not exist\nsecond" {
+		t.Errorf("unexpected msg %q", got.Msg)
+	}
+	if got.Type != "*fmt.wrapError" || got.File != "app.yaml" {
+		t.Errorf("unexpected type or wrap attrs: %+v", got)
+	}
+	if len(got.Chain) != 1 || len(got.Chain[0].Join) != 2 {
+		t.Fatalf("expected one joined link with two branches, got %+v", got.Chain)
+	}
+	if branch := got.Chain[0].Join[0]; len(branch) != 2 || branch[1].Type != "*errors.errorString" {
+		t.Errorf("unexpected first branch %+v", branch)
+	}
+	if len(got.Stack) == 0 {
+		t.Error("expected a stack captured by Wrap")
+	}
+}
+
+func Test_Error_NilDoesNotPanic(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := ToContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	Error(ctx, "nothing wrong", nil)
+
+	if !bytes.Contains(buf.Bytes(), []byte(`"error":null`)) {
+		t.Errorf("expected error=null, got %s", buf.String())
+	}
+}
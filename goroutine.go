@@ -0,0 +1,92 @@
+package xlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrGoroutinePanic is wrapped by the error of a goroutine started with Go or Group.Go
+// that panicked.
+var ErrGoroutinePanic = errors.New("xlog: goroutine panicked")
+
+// Go runs fn in a new goroutine that outlives the request: its context keeps the logger,
+// the With attrs and the request info of ctx but is not cancelled with it.
+//
+// Records logged through the context carry goroutine=name. A panic is recovered and
+// logged with its stack instead of crashing the process, and completion is logged with
+// the duration and error.
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		_ = runGoroutine(ctx, name, fn)
+	}()
+}
+
+// Group is an errgroup-like set of goroutines started with Go semantics. The first error
+// cancels the group context, and Wait returns it.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// NewGroup returns a group whose goroutines run in a context detached from ctx's
+// cancellation, and that context, which is cancelled by the first error or by Wait.
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	return &Group{ctx: ctx, cancel: cancel}, ctx
+}
+
+// Go runs fn in a new goroutine of the group, see the package level Go.
+func (g *Group) Go(name string, fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := runGoroutine(g.ctx, name, fn); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				g.cancel(err)
+			})
+		}
+	}()
+}
+
+// Wait blocks until every goroutine of the group has returned, and returns the first error.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(context.Canceled)
+	return g.err
+}
+
+// runGoroutine runs fn with the goroutine attr bound, turning a panic into an error.
+func runGoroutine(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	ctx = With(ctx, slog.String("goroutine", name))
+	start := time.Now()
+
+	defer func() {
+		if p := recover(); p != nil {
+			logPanic(ctx, p, callers(1, DefaultRecoverConfig.MaxFrames))
+			err = fmt.Errorf("%w: %s", ErrGoroutinePanic, panicMessage(p))
+		}
+
+		attrs := []slog.Attr{slog.Int64("duration_ms", time.Since(start).Milliseconds())}
+		level := slog.LevelInfo
+		if err != nil {
+			attrs = append(attrs, Err(err))
+			// Giving up because a sibling in the Group failed is not a failure of its own.
+			if !errors.Is(err, context.Canceled) || ctx.Err() == nil {
+				level = slog.LevelError
+			}
+		}
+		FromContext(ctx).LogAttrs(ctx, level, "goroutine finished", attrs...)
+	}()
+
+	return fn(ctx)
+}
@@ -0,0 +1,92 @@
+package xlog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer collects output from goroutines logging concurrently.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func Test_Go_DetachesAndRecovers(t *testing.T) {
+	var buf lockedBuffer
+	ctx, cancel := context.WithCancel(t.Context())
+	ctx = ToContext(ctx, slog.New(slog.NewJSONHandler(&buf, nil)))
+	ctx = With(ctx, "request_id", "req-1")
+
+	done := make(chan error, 1)
+	cancel() // the request is over before the goroutine starts
+	Go(ctx, "cleanup", func(ctx context.Context) error {
+		done <- ctx.Err()
+		panic("boom")
+	})
+	if err := <-done; err != nil {
+		t.Fatalf("expected the goroutine context not to be cancelled, got %v", err)
+	}
+
+	// Go has nothing to wait on; poll for the completion record.
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), `"msg":"goroutine finished"`) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`"msg":"PANIC","request_id":"req-1","goroutine":"cleanup","panic":"boom","stack":[`,
+		`"level":"ERROR","msg":"goroutine finished","request_id":"req-1","goroutine":"cleanup"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+
+	g, _ := NewGroup(ctx)
+	g.Go("panics", func(context.Context) error { panic("boom") })
+	if err := g.Wait(); !errors.Is(err, ErrGoroutinePanic) {
+		t.Errorf("expected the panic to surface as ErrGoroutinePanic, got %v", err)
+	}
+}
+
+func Test_Group_FirstErrorCancels(t *testing.T) {
+	var buf lockedBuffer
+	ctx := ToContext(t.Context(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	errBoom := errors.New("boom")
+	g, gctx := NewGroup(ctx)
+	g.Go("fails", func(context.Context) error { return errBoom })
+	g.Go("waits", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := g.Wait(); !errors.Is(err, errBoom) {
+		t.Errorf("expected the first error, got %v", err)
+	}
+	if !errors.Is(context.Cause(gctx), errBoom) {
+		t.Errorf("expected the group context to be cancelled by the error, got %v", context.Cause(gctx))
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"level":"INFO","msg":"goroutine finished","goroutine":"waits"`) ||
+		!strings.Contains(out, `"level":"ERROR","msg":"goroutine finished","goroutine":"fails"`) {
+		t.Errorf("expected a completion record per goroutine, got\n%s", out)
+	}
+}
@@ -3,6 +3,7 @@ package xlog
 import (
 	"context"
 	"log/slog"
+	"slices"
 )
 
 type XlogHandler struct {
@@ -10,22 +11,60 @@ type XlogHandler struct {
 
 	// function to add specific attributes/fields from a given context
 	attrFromContext []func(context.Context) []slog.Attr
+
+	// keys bound by the attach middlewares, which the extractors do not repeat
+	bound []string
 }
 
 func NewHandler(handler slog.Handler, attrFromContextFuncs ...func(context.Context) []slog.Attr) *XlogHandler {
 	return &XlogHandler{
-		handler,
-		attrFromContextFuncs,
+		handler:         handler,
+		attrFromContext: attrFromContextFuncs,
+	}
+}
+
+var (
+	_ slog.Handler   = (*XlogHandler)(nil)
+	_ trustedHandler = (*XlogHandler)(nil)
+)
+
+// trustedHandler is implemented by handlers that tell attrs from trusted sources, the
+// attach middlewares and the XlogHandler extractors, apart from the rest, so that
+// protected keys keep the trusted values. See DedupOptions.Protected.
+type trustedHandler interface {
+	withTrustedAttrs(attrs []slog.Attr) slog.Handler
+	// handleTrusted handles rec with the trusted attrs added.
+	handleTrusted(ctx context.Context, rec slog.Record, trusted []slog.Attr) error
+}
+
+// withTrustedAttrs is h.WithAttrs for attrs from a trusted source.
+func withTrustedAttrs(h slog.Handler, attrs []slog.Attr) slog.Handler {
+	if th, ok := h.(trustedHandler); ok {
+		return th.withTrustedAttrs(attrs)
 	}
+	return h.WithAttrs(attrs)
 }
 
-var _ slog.Handler = (*XlogHandler)(nil)
+// handleTrusted passes rec, which it consumes, to h with the trusted attrs added.
+func handleTrusted(h slog.Handler, ctx context.Context, rec slog.Record, trusted []slog.Attr) error {
+	if th, ok := h.(trustedHandler); ok {
+		return th.handleTrusted(ctx, rec, trusted)
+	}
+	rec.AddAttrs(trusted...)
+	return h.Handle(ctx, rec)
+}
 
+// Enabled also honors a per-request level override, see WithLevel.
 func (h *XlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return h.handler.Enabled(ctx, level)
+	return levelOverridden(ctx, level) || h.handler.Enabled(ctx, level)
 }
 
 func (h *XlogHandler) Handle(ctx context.Context, rec slog.Record) error {
+	return h.handleTrusted(ctx, rec, nil)
+}
+
+// handleTrusted also serves an outer XlogHandler, whose extracted attrs are in trusted.
+func (h *XlogHandler) handleTrusted(ctx context.Context, rec slog.Record, trusted []slog.Attr) error {
 	// Create a new record so we can safely append attrs
 	nr := slog.NewRecord(rec.Time, rec.Level, rec.Message, rec.PC)
 
@@ -35,15 +74,29 @@ func (h *XlogHandler) Handle(ctx context.Context, rec slog.Record) error {
 		return true
 	})
 
-	// 2) attrs extracted from context
+	// 2) attrs extracted from context. A handler that protects keys, such as the
+	// DedupHandler, gets them separately as coming from a trusted source.
+	th, _ := h.handler.(trustedHandler)
+	if th == nil {
+		nr.AddAttrs(trusted...)
+	}
 	for _, fn := range h.attrFromContext {
 		if fn == nil {
 			continue
 		}
-		for _, a := range fn(ctx) {
-			nr.AddAttrs(a)
+		attrs := unbound(fn(ctx), h.bound)
+		switch {
+		case th == nil:
+			nr.AddAttrs(attrs...)
+		case trusted == nil:
+			trusted = attrs
+		default:
+			trusted = append(slices.Clip(trusted), attrs...)
 		}
 	}
+	if th != nil && len(trusted) > 0 {
+		return th.handleTrusted(ctx, nr, trusted)
+	}
 
 	// Pass through to the wrapped handler; ReplaceAttr/AddSource apply there.
 	return h.handler.Handle(ctx, nr)
@@ -53,6 +106,21 @@ func (h *XlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return &XlogHandler{
 		handler:         h.handler.WithAttrs(attrs),
 		attrFromContext: h.attrFromContext,
+		bound:           h.bound,
+	}
+}
+
+// withTrustedAttrs remembers the keys, so that the request attrs bound by
+// MiddlewareAttachDefaultsLogger are not logged a second time by RequestInfoArgs.
+func (h *XlogHandler) withTrustedAttrs(attrs []slog.Attr) slog.Handler {
+	bound := slices.Clip(h.bound)
+	for _, a := range attrs {
+		bound = append(bound, a.Key)
+	}
+	return &XlogHandler{
+		handler:         withTrustedAttrs(h.handler, attrs),
+		attrFromContext: h.attrFromContext,
+		bound:           bound,
 	}
 }
 
@@ -60,26 +128,38 @@ func (h *XlogHandler) WithGroup(name string) slog.Handler {
 	return &XlogHandler{
 		handler:         h.handler.WithGroup(name),
 		attrFromContext: h.attrFromContext,
+		bound:           h.bound,
 	}
 }
 
-// // Returns a func that extracts any given keys from the context
-// func ExtractFromContext(keys ...any) func(ctx context.Context) []slog.Attr {
-// 	return func(ctx context.Context) []slog.Attr {
-// 		attrs := make([]slog.Attr, 0, len(keys))
-// 		for _, key := range keys {
-// 			attrs = append(attrs, slog.Any(key.(string), ctx.Value(key)))
-// 		}
-// 		return attrs
-// 	}
-// }
-
-// Extract the args slice directly from context
-func ExtractArgsFromContext(ctx context.Context) []slog.Attr {
-	if v := ctx.Value(ctxAttrsKey{}); v != nil {
-		if s, ok := v.([]slog.Attr); ok {
-			return s
+// unbound returns attrs without the bound keys, only allocating for a partial match.
+func unbound(attrs []slog.Attr, bound []string) []slog.Attr {
+	if len(bound) == 0 {
+		return attrs
+	}
+	kept := 0
+	for _, a := range attrs {
+		if !slices.Contains(bound, a.Key) {
+			kept++
+		}
+	}
+	switch kept {
+	case len(attrs):
+		return attrs
+	case 0:
+		return nil
+	}
+	out := make([]slog.Attr, 0, kept)
+	for _, a := range attrs {
+		if !slices.Contains(bound, a.Key) {
+			out = append(out, a)
 		}
 	}
-	return nil
+	return out
+}
+
+// Extract the request info attrs directly from context. Kept for existing setups,
+// it is equivalent to RequestInfoArgs.
+func ExtractArgsFromContext(ctx context.Context) []slog.Attr {
+	return RequestInfoArgs(ctx)
 }
@@ -0,0 +1,193 @@
+package xlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PublicError is what a client is allowed to see about an error.
+type PublicError struct {
+	Status  int
+	Message string
+	// Type is an optional problem type URI. Defaults to "about:blank".
+	Type string
+}
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// MIMEApplicationProblemJSON is the content type of Problem responses.
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// ErrorRegistry maps internal errors to public messages and status codes.
+// Matchers are tried in registration order; the first match wins.
+type ErrorRegistry struct {
+	mu       sync.RWMutex
+	matchers []func(error) (PublicError, bool)
+}
+
+func NewErrorRegistry() *ErrorRegistry {
+	return &ErrorRegistry{}
+}
+
+// DefaultErrorRegistry is used by HTTPErrorHandler when no registry is given.
+var DefaultErrorRegistry = NewErrorRegistry()
+
+// RegisterSentinel maps any error matching target via errors.Is to pe.
+func (r *ErrorRegistry) RegisterSentinel(target error, pe PublicError) {
+	r.Register(func(err error) (PublicError, bool) {
+		return pe, errors.Is(err, target)
+	})
+}
+
+// Register adds a custom matcher.
+func (r *ErrorRegistry) Register(match func(error) (PublicError, bool)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.matchers = append(r.matchers, match)
+}
+
+// RegisterType maps any error in the chain of type T (via errors.As) to pe.
+func RegisterType[T error](r *ErrorRegistry, pe PublicError) {
+	r.Register(func(err error) (PublicError, bool) {
+		var target T
+		return pe, errors.As(err, &target)
+	})
+}
+
+// Lookup returns the public view of err, if registered.
+func (r *ErrorRegistry) Lookup(err error) (PublicError, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, match := range r.matchers {
+		if pe, ok := match(err); ok {
+			return pe, true
+		}
+	}
+	return PublicError{}, false
+}
+
+// publicError resolves what the client may see about err. Registered errors win,
+// then echo.HTTPError (whose message is meant for clients, except on 5xx),
+// and anything else is an opaque 500.
+func (r *ErrorRegistry) publicError(err error) PublicError {
+	if pe, ok := r.Lookup(err); ok {
+		if pe.Status == 0 {
+			pe.Status = http.StatusInternalServerError
+		}
+		if pe.Message == "" {
+			pe.Message = http.StatusText(pe.Status)
+		}
+		return pe
+	}
+
+	pe := PublicError{Status: http.StatusInternalServerError}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		pe.Status = he.Code
+		if msg, ok := he.Message.(string); ok && he.Code < 500 {
+			pe.Message = msg
+		}
+	}
+	if pe.Message == "" {
+		pe.Message = http.StatusText(pe.Status)
+	}
+	return pe
+}
+
+// HTTPErrorHandler returns an echo.HTTPErrorHandler that logs the full internal error
+// and answers with a sanitized problem+json body carrying the request id, so support
+// can find the matching log line. A nil registry uses DefaultErrorRegistry.
+//
+//	e.HTTPErrorHandler = xlog.HTTPErrorHandler(nil)
+func HTTPErrorHandler(registry *ErrorRegistry) echo.HTTPErrorHandler {
+	if registry == nil {
+		registry = DefaultErrorRegistry
+	}
+	return func(err error, c echo.Context) {
+		// The request logger hands the error over and then returns it, as echo's
+		// RequestLogger does, so echo calls the handler again with the same error.
+		if handled, ok := c.Get(handledErrorKey).(error); ok && errors.Is(err, handled) {
+			return
+		}
+		c.Set(handledErrorKey, err)
+
+		pe := registry.publicError(err)
+		reqID := ensureRequestID(c)
+		ctx := c.Request().Context()
+
+		logHTTPError(ctx, pe.Status, err)
+
+		// A partly written response, e.g. a failed stream, cannot be replaced.
+		if c.Response().Committed {
+			return
+		}
+		if c.Request().Method == http.MethodHead {
+			_ = c.NoContent(pe.Status)
+			return
+		}
+		_ = writeProblem(c, pe, reqID)
+	}
+}
+
+// handledErrorKey is the echo context key for the error HTTPErrorHandler handled.
+const handledErrorKey = "xlog.handled_error"
+
+// logHTTPError logs err as the cause of an error response with the given status.
+// The error group carries the full chain, including echo.HTTPError.Internal.
+func logHTTPError(ctx context.Context, status int, err error) {
+	if status >= 500 {
+		Error(ctx, "HTTP_ERROR", err, slog.Int("status", status))
+	} else {
+		Warn(ctx, "HTTP_ERROR", slog.Int("status", status), Err(err))
+	}
+}
+
+// writeProblem writes pe as an RFC 7807 body.
+func writeProblem(c echo.Context, pe PublicError, reqID string) error {
+	b, err := problemJSON(pe, c.Request().URL.Path, reqID)
+	if err != nil {
+		return err
+	}
+	return c.Blob(pe.Status, MIMEApplicationProblemJSON, b)
+}
+
+// writeProblemHTTP is writeProblem for plain net/http.
+func writeProblemHTTP(w http.ResponseWriter, r *http.Request, pe PublicError, reqID string) error {
+	b, err := problemJSON(pe, r.URL.Path, reqID)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", MIMEApplicationProblemJSON)
+	w.WriteHeader(pe.Status)
+	_, err = w.Write(b)
+	return err
+}
+
+func problemJSON(pe PublicError, instance, reqID string) ([]byte, error) {
+	typ := pe.Type
+	if typ == "" {
+		typ = "about:blank"
+	}
+	return json.Marshal(Problem{
+		Type:      typ,
+		Title:     http.StatusText(pe.Status),
+		Status:    pe.Status,
+		Detail:    pe.Message,
+		Instance:  instance,
+		RequestID: reqID,
+	})
+}
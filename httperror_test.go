@@ -0,0 +1,130 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+var errTestNotFound = errors.New("card not found")
+
+type testValidationError struct{ field string }
+
+func (e *testValidationError) Error() string { return "invalid " + e.field }
+
+func Test_HTTPErrorHandler_SanitizesAndCorrelates(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	registry := NewErrorRegistry()
+	registry.RegisterSentinel(errTestNotFound, PublicError{Status: http.StatusNotFound, Message: "No such card"})
+	RegisterType[*testValidationError](registry, PublicError{Status: http.StatusUnprocessableEntity})
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler(registry)
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.GET("/sentinel", func(c echo.Context) error {
+		return fmt.Errorf("lookup 4111: %w", errTestNotFound)
+	})
+	e.GET("/typed", func(c echo.Context) error {
+		return fmt.Errorf("bind: %w", &testValidationError{"pan"})
+	})
+	e.GET("/internal", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadGateway, "upstream password=hunter2").
+			SetInternal(errors.New("dial tcp 10.0.0.1: refused"))
+	})
+
+	cases := []struct {
+		path   string
+		status int
+		detail string
+	}{
+		{"/sentinel", http.StatusNotFound, "No such card"},
+		{"/typed", http.StatusUnprocessableEntity, "Unprocessable Entity"},
+		{"/internal", http.StatusBadGateway, "Bad Gateway"},
+	}
+	for _, tc := range cases {
+		buf.Reset()
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		if rec.Code != tc.status {
+			t.Errorf("%s: want status %d, got %d", tc.path, tc.status, rec.Code)
+		}
+		if ct := rec.Header().Get(echo.HeaderContentType); ct != MIMEApplicationProblemJSON {
+			t.Errorf("%s: want problem content type, got %q", tc.path, ct)
+		}
+		var p Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%s: unmarshal: %v", tc.path, err)
+		}
+		if p.Detail != tc.detail || p.Status != tc.status {
+			t.Errorf("%s: unexpected problem %+v", tc.path, p)
+		}
+		if p.RequestID == "" || !strings.Contains(buf.String(), p.RequestID) {
+			t.Errorf("%s: request id %q not found in log\n%s", tc.path, p.RequestID, buf.String())
+		}
+	}
+
+	if !strings.Contains(buf.String(), "dial tcp 10.0.0.1") {
+		t.Errorf("expected internal error in log, got %s", buf.String())
+	}
+}
+
+func Test_HTTPErrorHandler_LogsAfterPartialWrite(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler(nil)
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.GET("/stream", func(c echo.Context) error {
+		_, _ = c.Response().Write([]byte("partial"))
+		return errors.New("stream broken")
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	if rec.Body.String() != "partial" {
+		t.Errorf("expected the partial body to be left alone, got %q", rec.Body.String())
+	}
+	if !strings.Contains(buf.String(), "stream broken") {
+		t.Errorf("expected the error to be logged, got %s", buf.String())
+	}
+}
+
+func Test_HTTPErrorHandler_LogsOnceWithRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler(nil)
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.Use(MiddlewareRequestLoggerSlog())
+	e.GET("/", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadGateway).SetInternal(errors.New("upstream down"))
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502, got %d", rec.Code)
+	}
+	out := buf.String()
+	if n := strings.Count(out, `"msg":"HTTP_ERROR"`); n != 1 {
+		t.Errorf("expected exactly one HTTP_ERROR line, got %d:\n%s", n, out)
+	}
+	if n := strings.Count(out, `"msg":"REQUEST_ERROR"`); n != 1 {
+		t.Errorf("expected exactly one REQUEST_ERROR line, got %d:\n%s", n, out)
+	}
+}
@@ -0,0 +1,121 @@
+package xlog
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// IPAnonymization decides how much of the client IP is kept.
+type IPAnonymization int
+
+const (
+	// IPKeep logs the full address.
+	IPKeep IPAnonymization = iota
+	// IPTruncate zeroes the host part: IPv4 is cut to /24, IPv6 to /48.
+	IPTruncate
+	// IPHash logs a keyed hash of the address, see Pseudonymize. Requests from the same
+	// client can still be correlated without storing the address.
+	IPHash
+	// IPDrop logs no address at all.
+	IPDrop
+)
+
+// IPPolicy resolves and anonymizes the client IP. Used by the attach middlewares through
+// WithIPPolicy and by the request loggers through RequestLoggerConfig.IPPolicy.
+type IPPolicy struct {
+	// TrustedProxies are the networks whose X-Forwarded-For and X-Real-IP headers are
+	// believed. Without any, forwarding headers are ignored and the connection address is used.
+	TrustedProxies []netip.Prefix
+
+	Anonymize IPAnonymization
+	// HashKey is the HMAC key for IPHash. Without a key IPHash behaves like IPDrop.
+	HashKey []byte
+}
+
+// ClientIP returns the anonymized client IP of r, or "" if it is dropped or unknown.
+func (p *IPPolicy) ClientIP(r *http.Request) string {
+	addr, ok := p.clientAddr(r)
+	if !ok {
+		return ""
+	}
+
+	switch p.Anonymize {
+	case IPTruncate:
+		bits := 48
+		if addr.Is4() {
+			bits = 24
+		}
+		prefix, _ := addr.Prefix(bits)
+		return prefix.Addr().String()
+	case IPHash:
+		if len(p.HashKey) == 0 {
+			return ""
+		}
+		return Pseudonymize(p.HashKey, addr.String())
+	case IPDrop:
+		return ""
+	default:
+		return addr.String()
+	}
+}
+
+// clientAddr walks X-Forwarded-For from the right, skipping trusted proxies, so a client
+// cannot spoof its address by sending the header itself.
+func (p *IPPolicy) clientAddr(r *http.Request) (netip.Addr, bool) {
+	remote, ok := parseAddr(r.RemoteAddr)
+	if !ok || !p.trusted(remote) {
+		return remote, ok
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	client := remote
+	for _, hop := range slices.Backward(hops) {
+		addr, ok := parseAddr(strings.TrimSpace(hop))
+		if !ok {
+			break
+		}
+		client = addr
+		if !p.trusted(addr) {
+			return addr, true
+		}
+	}
+	if len(hops) == 0 {
+		if addr, ok := parseAddr(r.Header.Get("X-Real-IP")); ok {
+			return addr, true
+		}
+	}
+	return client, true
+}
+
+func (p *IPPolicy) trusted(addr netip.Addr) bool {
+	return slices.ContainsFunc(p.TrustedProxies, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
+
+// remoteAddrIP returns the address r was received from, ignoring forwarding headers.
+func remoteAddrIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parseAddr accepts a bare address or host:port, with or without IPv6 brackets.
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,78 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_IPPolicy_ClientIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	key := []byte("k")
+
+	for _, tt := range []struct {
+		name   string
+		policy IPPolicy
+		remote string
+		xff    string
+		want   string
+	}{
+		{"untrusted remote ignores header", IPPolicy{TrustedProxies: proxies}, "203.0.113.9:1234", "198.51.100.1", "203.0.113.9"},
+		{"no proxies ignores header", IPPolicy{}, "10.0.0.1:1234", "198.51.100.1", "10.0.0.1"},
+		{"trusted proxy", IPPolicy{TrustedProxies: proxies}, "10.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"spoofed left entry", IPPolicy{TrustedProxies: proxies}, "10.0.0.1:1234", "1.2.3.4, 198.51.100.1, 10.0.0.2", "198.51.100.1"},
+		{"truncate v4", IPPolicy{Anonymize: IPTruncate}, "203.0.113.9:1234", "", "203.0.113.0"},
+		{"truncate v6", IPPolicy{Anonymize: IPTruncate}, "[2001:db8:1234:5678::1]:1234", "", "2001:db8:1234::"},
+		{"truncate mapped v4", IPPolicy{Anonymize: IPTruncate}, "[::ffff:203.0.113.9]:1234", "", "203.0.113.0"},
+		{"hash", IPPolicy{Anonymize: IPHash, HashKey: key}, "203.0.113.9:1234", "", Pseudonymize(key, "203.0.113.9")},
+		{"hash without key", IPPolicy{Anonymize: IPHash}, "203.0.113.9:1234", "", ""},
+		{"drop", IPPolicy{Anonymize: IPDrop}, "203.0.113.9:1234", "", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := tt.policy.ClientIP(r); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_IPPolicy_RequestLoggerAndInfo(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	policy := &IPPolicy{Anonymize: IPTruncate}
+
+	var info RequestInfo
+	e := echo.New()
+	e.Use(MiddlewareAttachDefaultsLogger(logger, WithIPPolicy(policy)))
+	e.Use(MiddlewareRequestLoggerWithConfig(RequestLoggerConfig{LogRemoteIP: true, IPPolicy: policy}))
+	e.GET("/", func(c echo.Context) error {
+		info, _ = RequestInfoFromContext(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.9:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	var logged struct {
+		RemoteIP string `json:"remote_ip"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &logged); err != nil {
+		t.Fatalf("unmarshal log: %v\n%s", err, buf.String())
+	}
+	if logged.RemoteIP != "203.0.113.0" || info.RemoteIP != "203.0.113.0" {
+		t.Errorf("expected the truncated connection address, got log %q, info %q", logged.RemoteIP, info.RemoteIP)
+	}
+}
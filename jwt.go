@@ -0,0 +1,50 @@
+package xlog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// bearerClaims decodes the payload of a bearer JWT in r's Authorization header.
+//
+// The signature is NOT verified. Only use the claims for logging, or behind middleware
+// that has already authenticated the token.
+func bearerClaims(r *http.Request) (map[string]any, bool) {
+	auth := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok {
+		token, ok = strings.CutPrefix(auth, "bearer ")
+	}
+	if !ok {
+		return nil, false
+	}
+
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, false
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, false
+	}
+	return claims, true
+}
+
+// stringClaim returns claim as a string; numbers are accepted too.
+func stringClaim(claims map[string]any, claim string) (string, bool) {
+	switch v := claims[claim].(type) {
+	case string:
+		return v, v != ""
+	case float64:
+		b, _ := json.Marshal(v)
+		return string(b), true
+	default:
+		return "", false
+	}
+}
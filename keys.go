@@ -0,0 +1,122 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// ContextKey is a context key that knows how to log its value. *Key[T] implements it.
+type ContextKey interface {
+	Name() string
+	// Attr returns the logged attr for the value in ctx, false if there is none.
+	Attr(ctx context.Context) (slog.Attr, bool)
+}
+
+// Key is a typed context key. Its value is logged under Name with the type preserved:
+// ints stay ints and time.Time stays a time, unless a KeyFormat is given.
+//
+//	var OrderID = xlog.NewKey[int64]("order_id")
+//	ctx = OrderID.WithValue(ctx, 42)
+//	handler := xlog.NewHandler(inner, xlog.Extract(OrderID))
+type Key[T any] struct {
+	name   string
+	format func(T) slog.Value
+}
+
+// KeyOption configures a Key.
+type KeyOption[T any] func(*Key[T])
+
+// KeyFormat sets how the value is logged.
+func KeyFormat[T any](format func(T) slog.Value) KeyOption[T] {
+	return func(k *Key[T]) {
+		k.format = format
+	}
+}
+
+func NewKey[T any](name string, opts ...KeyOption[T]) *Key[T] {
+	k := &Key[T]{name: name}
+	for _, opt := range opts {
+		opt(k)
+	}
+	return k
+}
+
+var _ ContextKey = (*Key[int])(nil)
+
+func (k *Key[T]) Name() string {
+	return k.name
+}
+
+// WithValue returns ctx carrying v under k.
+func (k *Key[T]) WithValue(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, k, v)
+}
+
+// Value returns the value stored under k, false if there is none.
+func (k *Key[T]) Value(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+func (k *Key[T]) Attr(ctx context.Context) (slog.Attr, bool) {
+	v, ok := k.Value(ctx)
+	if !ok {
+		return slog.Attr{}, false
+	}
+	if k.format != nil {
+		return slog.Attr{Key: k.name, Value: k.format(v)}, true
+	}
+	return slog.Any(k.name, v), true
+}
+
+// Extract returns an XlogHandler extractor that logs the given keys, skipping absent values.
+func Extract(keys ...ContextKey) func(context.Context) []slog.Attr {
+	return func(ctx context.Context) []slog.Attr {
+		return extractKeys(ctx, keys)
+	}
+}
+
+func extractKeys(ctx context.Context, keys []ContextKey) []slog.Attr {
+	var attrs []slog.Attr
+	for _, k := range keys {
+		if a, ok := k.Attr(ctx); ok {
+			if attrs == nil {
+				attrs = make([]slog.Attr, 0, len(keys))
+			}
+			attrs = append(attrs, a)
+		}
+	}
+	return attrs
+}
+
+// Registry of keys logged by RegisteredArgs. Reads are lock free; writes copy.
+var (
+	registeredMu   sync.Mutex
+	registeredKeys atomic.Pointer[[]ContextKey]
+)
+
+// RegisterKeys adds keys to the global registry, so that libraries can have their
+// context values logged without touching the handler setup. The application opts in
+// once by adding RegisteredArgs to its XlogHandler.
+func RegisterKeys(keys ...ContextKey) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+
+	var next []ContextKey
+	if cur := registeredKeys.Load(); cur != nil {
+		next = append(next, *cur...)
+	}
+	next = append(next, keys...)
+	registeredKeys.Store(&next)
+}
+
+// RegisteredArgs is an XlogHandler extractor for every key added with RegisterKeys.
+func RegisteredArgs(ctx context.Context) []slog.Attr {
+	keys := registeredKeys.Load()
+	if keys == nil {
+		return nil
+	}
+	return extractKeys(ctx, *keys)
+}
@@ -0,0 +1,49 @@
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Extract_TypedKeys(t *testing.T) {
+	orderID := NewKey[int64]("order_id")
+	placed := NewKey[time.Time]("placed_at")
+	sku := NewKey[string]("sku", KeyFormat(func(s string) slog.Value {
+		return slog.StringValue(strings.ToUpper(s))
+	}))
+	missing := NewKey[bool]("missing")
+	libKey := NewKey[int]("lib_retries")
+	prev := registeredKeys.Load()
+	t.Cleanup(func() { registeredKeys.Store(prev) })
+	RegisterKeys(libKey)
+
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil),
+		Extract(orderID, placed, sku, missing), RegisteredArgs))
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ctx := orderID.WithValue(context.Background(), 42)
+	ctx = placed.WithValue(ctx, at)
+	ctx = sku.WithValue(ctx, "ab-1")
+	ctx = libKey.WithValue(ctx, 3)
+	logger.InfoContext(ctx, "placed")
+
+	var rec map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &rec); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, buf.String())
+	}
+	if rec["order_id"] != float64(42) || rec["sku"] != "AB-1" || rec["lib_retries"] != float64(3) {
+		t.Errorf("unexpected typed attrs: %v", rec)
+	}
+	if rec["placed_at"] != at.Format(time.RFC3339) {
+		t.Errorf("expected placed_at as a time, got %v", rec["placed_at"])
+	}
+	if _, ok := rec["missing"]; ok {
+		t.Errorf("expected absent keys to be skipped, got %v", rec)
+	}
+}
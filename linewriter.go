@@ -0,0 +1,74 @@
+package xlog
+
+import (
+	"bytes"
+	"sync"
+	"unicode/utf8"
+)
+
+// lineWriter is an io.Writer that calls emit once per complete line written to it,
+// without the line ending. Partial lines are held until their newline arrives or Flush.
+//
+// With max set, longer lines are cut to max bytes and emitted with truncated set;
+// the rest of such a line is dropped.
+type lineWriter struct {
+	emit func(line string, truncated bool)
+	max  int
+
+	mu       sync.Mutex
+	buf      []byte
+	dropping bool // inside a line that was already emitted truncated
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			if w.max > 0 && len(w.buf) > w.max {
+				if !w.dropping {
+					w.emitLocked(w.buf)
+				}
+				w.dropping = true
+				w.buf = nil
+			}
+			break
+		}
+		if w.dropping {
+			w.dropping = false
+		} else {
+			w.emitLocked(bytes.TrimSuffix(w.buf[:i], []byte{'\r'}))
+		}
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil // drop the consumed backing array
+	}
+	return len(p), nil
+}
+
+// Flush emits a pending partial line.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 && !w.dropping {
+		w.emitLocked(w.buf)
+	}
+	w.buf, w.dropping = nil, false
+}
+
+func (w *lineWriter) emitLocked(line []byte) {
+	if w.max <= 0 || len(line) <= w.max {
+		w.emit(string(line), false)
+		return
+	}
+	// Cut on a rune boundary.
+	n := w.max
+	for n > 0 && !utf8.RuneStart(line[n]) {
+		n--
+	}
+	w.emit(string(line[:n]), true)
+}
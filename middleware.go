@@ -3,135 +3,146 @@ package xlog
 import (
 	"context"
 	"log/slog"
+	"time"
 
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 )
 
-type ctxAttrsKey struct{}
+// AttachOption configures the attach middlewares.
+type AttachOption func(*attachOptions)
+
+type attachOptions struct {
+	sampler *TailSampler
+	ip      *IPPolicy
+}
+
+// WithTailSampling buffers every record of the request and emits or drops them together
+// once the request is done, see TailSampler.
+func WithTailSampling(s *TailSampler) AttachOption {
+	return func(o *attachOptions) {
+		o.sampler = s
+	}
+}
+
+// WithIPPolicy resolves RequestInfo.RemoteIP with p, which can believe forwarding
+// headers from trusted proxies and anonymize the address, see IPPolicy. Without it the
+// connection address is used.
+func WithIPPolicy(p *IPPolicy) AttachOption {
+	return func(o *attachOptions) {
+		o.ip = p
+	}
+}
+
+func newAttachOptions(opts []AttachOption) attachOptions {
+	var o attachOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// begin installs the per-request machinery selected by the options in ctx.
+func (o attachOptions) begin(ctx context.Context) context.Context {
+	if o.sampler != nil {
+		ctx = withSampleBuffer(ctx, o.sampler)
+	}
+	return ctx
+}
+
+// end finishes the request in ctx with the outcome seen by the attach middleware.
+func (o attachOptions) end(ctx context.Context, status int, err error) {
+	if o.sampler != nil {
+		// No-op if the request logger already decided with the final status.
+		info, _ := RequestInfoFromContext(ctx)
+		finishSampling(ctx, status, err, time.Since(info.Start))
+	}
+}
+
+// serve runs next with ctx as the request context, wrapped in the per-request
+// machinery selected by the options.
+func (o attachOptions) serve(c echo.Context, ctx context.Context, next echo.HandlerFunc) error {
+	c.SetRequest(c.Request().WithContext(o.begin(ctx)))
+	err := next(c)
+	o.end(c.Request().Context(), c.Response().Status, err)
+	return err
+}
+
+// attachLogger stores info in ctx, along with logger bound to the info attrs as
+// coming from a trusted source. An XlogHandler extractor does not repeat them.
+func attachLogger(ctx context.Context, info RequestInfo, logger *slog.Logger) context.Context {
+	ctx = RequestInfoToContext(ctx, info)
+	return ToContext(ctx, slog.New(withTrustedAttrs(logger.Handler(), info.Attrs())))
+}
+
+// bindTrusted binds attrs to the logger in ctx like attachLogger does, for request info
+// learned once the logger is attached.
+func bindTrusted(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return ToContext(ctx, slog.New(withTrustedAttrs(baseLogger(ctx).Handler(), attrs)))
+}
 
 // Request-scoped slog.Logger to the context with default per-req attrs.
+// The request info is stored as well, see RequestInfoFromContext; an XlogHandler
+// extractor such as RequestInfoArgs does not log the bound attrs again.
 //
 // Calling Info on this method: 319.1 ns/op	       0 B/op	       0 allocs/op
-func MiddlewareAttachDefaultsLogger(logger *slog.Logger) echo.MiddlewareFunc {
+func MiddlewareAttachDefaultsLogger(logger *slog.Logger, opts ...AttachOption) echo.MiddlewareFunc {
+	o := newAttachOptions(opts)
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			tenant := GetTenant(c)
+			info := echoRequestInfo(c, o.ip)
 
-			req := c.Request()
-			reqID := c.Response().Header().Get(echo.HeaderXRequestID)
+			// Add a logger with default values to context so that we can call it.
+			ctx := attachLogger(c.Request().Context(), info, logger)
 
-			// Create a logger with default values
-			reqLogger := logger.With(
-				slog.String(string(CtxTenantKey), tenant),
-				slog.String(string(CtxMethodKey), req.Method),
-				slog.String(string(CtxURIPathKey), req.URL.Path),
-				slog.String(string(CtxReqIDKey), reqID),
-			)
-
-			// Add the logger to context so that we can call it.
-			ctx := ToContext(req.Context(), reqLogger)
-			c.SetRequest(req.WithContext(ctx))
-
-			return next(c)
+			return o.serve(c, ctx, next)
 		}
 	}
 }
 
-// Attach Default Per Request attributes to the context.
+// Attach Default Per Request attributes to the context as RequestInfo.
+// Pair it with an XlogHandler using RequestInfoArgs, ExtractArgsFromContext or DefaultPerRequestArgs.
 //
 // Benchmark:	       503.3 ns/op	       0 B/op	       0 allocs/op
-func MiddlewareAttachDefaultsCtx(logger *slog.Logger) echo.MiddlewareFunc {
+func MiddlewareAttachDefaultsCtx(logger *slog.Logger, opts ...AttachOption) echo.MiddlewareFunc {
+	o := newAttachOptions(opts)
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			tenant := GetTenant(c)
-
+			info := echoRequestInfo(c, o.ip)
 			req := c.Request()
-			reqID := c.Response().Header().Get(echo.HeaderXRequestID)
-
-			// Create default attrs and store the slice:
-			attrs := []slog.Attr{
-				slog.String(string(CtxTenantKey), tenant),
-				slog.String(string(CtxReqIDKey), reqID),
-				slog.String(string(CtxMethodKey), c.Request().Method),
-				slog.String(string(CtxURIPathKey), c.Request().URL.Path),
-			}
 
-			ctx := context.WithValue(req.Context(), ctxAttrsKey{}, attrs)
+			ctx := RequestInfoToContext(req.Context(), info)
 
-			//ctx := ContextWithLogger(req.Context(), reqLogger)
-			c.SetRequest(req.WithContext(ctx))
-
-			return next(c)
+			return o.serve(c, ctx, next)
 		}
 	}
 }
 
-// Attach Default Per Request attributes to the context.
+// Attach Default Per Request attributes to the context as RequestInfo, and additionally
+// under the individual ctxKey constants for code that reads those directly.
 //
 //	Calling Info on this method: 793.2 ns/op	     336 B/op	       7 allocs/op
-func MiddlewareAttachDefaultsCtxOld(logger *slog.Logger) echo.MiddlewareFunc {
+func MiddlewareAttachDefaultsCtxOld(logger *slog.Logger, opts ...AttachOption) echo.MiddlewareFunc {
+	o := newAttachOptions(opts)
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			tenant := GetTenant(c)
-
+			info := echoRequestInfo(c, o.ip)
 			req := c.Request()
-			reqID := c.Response().Header().Get(echo.HeaderXRequestID)
 
 			// Allows simple slog.InfoContext calls to also return these values rather than requiring the use of xlog.Level() funcs
-			ctx := req.Context()
-			ctx = context.WithValue(ctx, CtxTenantKey, tenant)
-			ctx = context.WithValue(ctx, CtxReqIDKey, reqID)
-			ctx = context.WithValue(ctx, CtxMethodKey, c.Request().Method)
-			ctx = context.WithValue(ctx, CtxURIPathKey, c.Request().URL.Path)
-
-			//ctx := ContextWithLogger(req.Context(), reqLogger)
-			c.SetRequest(req.WithContext(ctx))
+			ctx := RequestInfoToContext(req.Context(), info)
+			ctx = context.WithValue(ctx, CtxTenantKey, info.Tenant)
+			ctx = context.WithValue(ctx, CtxReqIDKey, info.RequestID)
+			ctx = context.WithValue(ctx, CtxMethodKey, info.Method)
+			ctx = context.WithValue(ctx, CtxURIPathKey, info.Path)
 
-			return next(c)
+			return o.serve(c, ctx, next)
 		}
 	}
 }
 
-// Per request final log for echo
-// TODO: Alternative error messages for frontend?
+// Per request final log for echo, using DefaultRequestLoggerConfig.
+// See MiddlewareRequestLoggerWithConfig for a configurable version, and HTTPErrorHandler
+// for client-safe error responses.
 func MiddlewareRequestLoggerSlog() echo.MiddlewareFunc {
-	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
-		LogStatus:    true,
-		LogURI:       true,
-		LogURIPath:   true,
-		LogError:     true,
-		HandleError:  true,
-		LogRequestID: true,
-		LogMethod:    true,
-		LogLatency:   true,
-		LogHost:      true,
-		LogRemoteIP:  true,
-		LogUserAgent: true,
-		LogReferer:   true,
-		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
-			attrs := []slog.Attr{
-				slog.Int("status", v.Status),
-				slog.Int64("duration_ms", v.Latency.Milliseconds()),
-				slog.String("uri", v.URI),
-				slog.String("user_agent", v.UserAgent),
-				slog.String("host", v.Host),
-				slog.String("remote_ip", v.RemoteIP),
-				slog.String("referer", v.Referer),
-			}
-
-			// Pulls the logger from context to include any attached values, such as the request id.
-			logger := FromContext(c.Request().Context())
-
-			if v.Error == nil {
-				logger.LogAttrs(c.Request().Context(), slog.LevelInfo, "REQUEST", attrs...)
-			} else {
-				attrs = append(attrs,
-					slog.String("error", v.Error.Error()),
-				)
-				logger.LogAttrs(c.Request().Context(), slog.LevelError, "REQUEST_ERROR", attrs...)
-			}
-			return nil
-		},
-	})
+	return MiddlewareRequestLoggerWithConfig(DefaultRequestLoggerConfig)
 }
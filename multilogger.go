@@ -33,6 +33,15 @@ func (m *MultiHandler) Handle(ctx context.Context, r slog.Record) error {
 	return nil
 }
 
+func (m *MultiHandler) handleTrusted(ctx context.Context, r slog.Record, trusted []slog.Attr) error {
+	for _, h := range m.handlers {
+		if err := handleTrusted(h, ctx, r.Clone(), trusted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	nh := make([]slog.Handler, len(m.handlers))
 	for i, h := range m.handlers {
@@ -41,6 +50,14 @@ func (m *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return &MultiHandler{handlers: nh}
 }
 
+func (m *MultiHandler) withTrustedAttrs(attrs []slog.Attr) slog.Handler {
+	nh := make([]slog.Handler, len(m.handlers))
+	for i, h := range m.handlers {
+		nh[i] = withTrustedAttrs(h, attrs)
+	}
+	return &MultiHandler{handlers: nh}
+}
+
 func (m *MultiHandler) WithGroup(name string) slog.Handler {
 	nh := make([]slog.Handler, len(m.handlers))
 	for i, h := range m.handlers {
@@ -0,0 +1,171 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// The HTTP* middlewares are the plain net/http counterparts of the echo middlewares and
+// share their behavior. They skip requests by the HTTPSkipper fields of the configs,
+// the echo specific Skipper fields are not consulted. A typical chain:
+//
+//	var h http.Handler = mux
+//	h = xlog.HTTPRecover(xlog.DefaultRecoverConfig)(h)
+//	h = xlog.HTTPRequestLogger(xlog.DefaultRequestLoggerConfig)(h)
+//	h = xlog.HTTPAttachDefaults(logger)(h)
+//	h = xlog.HTTPTenant(xlog.TenantConfig{Optional: true})(h)
+//
+// Unlike echo, net/http does not hand context changes made further in back to outer
+// middleware, so the attach middleware must wrap the request logger and the recoverer
+// for their lines to carry the request attrs.
+
+// HTTPSkipper is middleware.Skipper for the net/http middlewares.
+type HTTPSkipper func(r *http.Request) bool
+
+func defaultHTTPSkipper(*http.Request) bool {
+	return false
+}
+
+// HTTPRequestID is MiddlewareRequestID for net/http.
+func HTTPRequestID(config RequestIDConfig) func(http.Handler) http.Handler {
+	config = config.withDefaults()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.HTTPSkipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, _ := config.resolve(r.Context(), r, w.Header())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// HTTPAttachDefaults is MiddlewareAttachDefaultsLogger for net/http.
+// The route is not known yet at this point, since the ServeMux matches it further in.
+func HTTPAttachDefaults(logger *slog.Logger, opts ...AttachOption) func(http.Handler) http.Handler {
+	o := newAttachOptions(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newResponseRecorder(w)
+			ctx, info := httpRequestInfo(rec, r, o.ip)
+
+			ctx = o.begin(attachLogger(ctx, info, logger))
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			o.end(ctx, rec.Status(), nil)
+		})
+	}
+}
+
+// HTTPRequestLogger is MiddlewareRequestLoggerWithConfig for net/http.
+// The route is the ServeMux pattern that matched, e.g. "GET /users/{id}".
+func HTTPRequestLogger(config RequestLoggerConfig) func(http.Handler) http.Handler {
+	config = config.withDefaults()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.HTTPSkipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			config.serve(w, r, func(w http.ResponseWriter, r *http.Request) servedRequest {
+				// ServeMux records the matched pattern on the request it is given, so
+				// pass our own copy and read it back afterwards.
+				r = r.WithContext(r.Context())
+				next.ServeHTTP(w, r)
+				return servedRequest{Request: r, Route: r.Pattern, RemoteIP: remoteAddrIP(r)}
+			})
+		})
+	}
+}
+
+// HTTPBodyCapture is MiddlewareBodyCapture for net/http. Install it in front of
+// HTTPRequestLogger.
+func HTTPBodyCapture(config BodyCaptureConfig) func(http.Handler) http.Handler {
+	bc := newBodyCapturer(config)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bc.HTTPSkipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rec, r := bc.wrap(w, r)
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// HTTPTenant is MiddlewareTenant for net/http. Failures are logged like HTTPErrorHandler
+// does and answered with a problem body.
+//
+// TenantFromPathParam only sees path values when the middleware wraps a handler
+// registered on a ServeMux, not the mux itself.
+func HTTPTenant(config TenantConfig) func(http.Handler) http.Handler {
+	config = config.withDefaults()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.HTTPSkipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, _, err := config.apply(r)
+			if err != nil {
+				// Outermost in the usual chain, so the request id is usually resolved here.
+				ctx, reqID := DefaultRequestIDConfig.withDefaults().resolve(ctx, r, w.Header())
+				if RequestID(r.Context()) == "" {
+					ctx = bindTrusted(ctx, slog.String(string(CtxReqIDKey), reqID))
+				}
+				status, msg := tenantErrorStatus(err)
+				logHTTPError(ctx, status, err)
+				_ = writeProblemHTTP(w, r, PublicError{Status: status, Message: msg}, reqID)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// HTTPRecover is MiddlewareRecover for net/http.
+func HTTPRecover(config RecoverConfig) func(http.Handler) http.Handler {
+	config = config.withDefaults()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.HTTPSkipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rec := newResponseRecorder(w)
+			defer config.recoverPanic(rec, func() *http.Request { return r }, func() bool { return rec.wroteHeader })
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// httpRequestInfo is echoRequestInfo for net/http. The returned ctx carries the request id.
+func httpRequestInfo(w http.ResponseWriter, r *http.Request, ip *IPPolicy) (context.Context, RequestInfo) {
+	ctx, reqID := DefaultRequestIDConfig.withDefaults().resolve(r.Context(), r, w.Header())
+	remoteIP := remoteAddrIP(r)
+	if ip != nil {
+		remoteIP = ip.ClientIP(r)
+	}
+	return ctx, newRequestInfo(ctx, r, requestTenant(ctx, r), reqID, r.Pattern, remoteIP)
+}
+
+// requestTenant is GetTenant for net/http: the tenant resolved by HTTPTenant, or else
+// the first tenant found by DefaultTenantResolvers.
+func requestTenant(ctx context.Context, r *http.Request) string {
+	if tenant := Tenant(ctx); tenant != "" {
+		return tenant
+	}
+	for _, res := range DefaultTenantResolvers {
+		if tenant, ok := res.ResolveTenant(r); ok {
+			return tenant
+		}
+	}
+	return ""
+}
@@ -0,0 +1,167 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newHTTPTestChain(logger *slog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		Info(r.Context(), "handler")
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	var h http.Handler = mux
+	h = HTTPRecover(DefaultRecoverConfig)(h)
+	h = HTTPRequestLogger(RequestLoggerConfig{LogStatus: true, LogRoute: true, LogSizes: true})(h)
+	h = HTTPAttachDefaults(logger)(h)
+	h = HTTPTenant(TenantConfig{Optional: true})(h)
+	return h
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("unmarshal %q: %v", line, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func Test_HTTPMiddleware_LogsRequest(t *testing.T) {
+	var buf bytes.Buffer
+	h := newHTTPTestChain(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/7?tenantId=ACME", nil))
+
+	reqID := rec.Header().Get("X-Request-ID")
+	if rec.Code != http.StatusOK || reqID == "" {
+		t.Fatalf("expected 200 with a request id, got %d %q", rec.Code, reqID)
+	}
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected handler and request lines, got %d:\n%s", len(lines), buf.String())
+	}
+	for _, l := range lines {
+		if l["tenant"] != "acme" || l["request_id"] != reqID {
+			t.Errorf("expected request attrs on every line, got %v", l)
+		}
+	}
+	final := lines[1]
+	if final["msg"] != "REQUEST" || final["status"] != float64(200) ||
+		final["route"] != "GET /users/{id}" || final["bytes_out"] != float64(2) {
+		t.Errorf("unexpected request line: %v", final)
+	}
+}
+
+func Test_HTTPMiddleware_RecoversPanic(t *testing.T) {
+	var buf bytes.Buffer
+	h := newHTTPTestChain(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	var body Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if body.RequestID == "" || body.RequestID != rec.Header().Get("X-Request-ID") {
+		t.Errorf("expected body to carry the request id, got %+v", body)
+	}
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 || lines[0]["msg"] != "PANIC" || lines[1]["status"] != float64(500) {
+		t.Fatalf("expected PANIC then a 500 request line, got:\n%s", buf.String())
+	}
+	if lines[0]["request_id"] != body.RequestID {
+		t.Errorf("expected the panic to be logged with the request id, got %v", lines[0])
+	}
+}
+
+func Test_HTTPTenant_Rejects(t *testing.T) {
+	var buf bytes.Buffer
+	h := HTTPTenant(TenantConfig{Allowed: []string{"acme"}})(http.NotFoundHandler())
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/?tenantId=other", nil)
+	req = req.WithContext(ToContext(req.Context(), slog.New(slog.NewJSONHandler(&buf, nil))))
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Content-Type") != MIMEApplicationProblemJSON {
+		t.Errorf("expected a 403 problem, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(buf.String(), `"msg":"HTTP_ERROR"`) {
+		t.Errorf("expected the rejection to be logged, got %s", buf.String())
+	}
+	var body Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if reqID := rec.Header().Get("X-Request-ID"); reqID == "" || body.RequestID != reqID ||
+		!strings.Contains(buf.String(), `"request_id":"`+reqID+`"`) {
+		t.Errorf("expected the rejection to carry the request id, got body %+v and log %s", body, buf.String())
+	}
+}
+
+func Test_HTTPRequestLogger_SkipperAndRemoteIP(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	h := HTTPRequestLogger(RequestLoggerConfig{
+		LogStatus:   true,
+		LogRemoteIP: true,
+		HTTPSkipper: func(r *http.Request) bool { return r.URL.Path == "/health" },
+	})(http.NotFoundHandler())
+
+	for _, target := range []string{"/health", "/users"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+		h.ServeHTTP(httptest.NewRecorder(), req.WithContext(ToContext(req.Context(), logger)))
+	}
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 1 {
+		t.Fatalf("expected only the unskipped request to be logged, got:\n%s", buf.String())
+	}
+	if lines[0]["remote_ip"] != "192.0.2.1" || lines[0]["status"] != float64(404) {
+		t.Errorf("expected the connection address and a 404, got %v", lines[0])
+	}
+}
+
+func Test_responseRecorder_PreservesInterfaces(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := newResponseRecorder(rec)
+	if newResponseRecorder(w) != w {
+		t.Error("expected an already wrapped writer to be reused")
+	}
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		t.Errorf("expected Flush to reach the recorder, got %v", err)
+	}
+	if !rec.Flushed || w.Status() != http.StatusOK {
+		t.Errorf("expected a flushed 200, got flushed=%v status=%d", rec.Flushed, w.Status())
+	}
+	if _, _, err := rc.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported from a non-hijackable writer, got %v", err)
+	}
+}
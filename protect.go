@@ -0,0 +1,106 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ProtectAction decides what happens to an attempt to overwrite a protected key.
+type ProtectAction int
+
+const (
+	// ProtectRename keeps the attempted value under Prefix+key, e.g. user.tenant.
+	ProtectRename ProtectAction = iota
+	// ProtectReject drops the attempted value.
+	ProtectReject
+)
+
+// ProtectedKeys declares keys, such as tenant and request_id, that user code may not
+// overwrite once they are bound. Every attempt is reported with a debug-level diagnostic.
+type ProtectedKeys struct {
+	Keys   []string
+	Action ProtectAction
+	// Prefix for renamed keys. Defaults to "user.".
+	Prefix string
+}
+
+// DefaultProtectedKeys protects the security relevant request attrs.
+var DefaultProtectedKeys = ProtectedKeys{
+	Keys: []string{string(CtxTenantKey), string(CtxReqIDKey)},
+}
+
+// Key for the protected keys declared with Protect.
+type ctxProtectedKey struct{}
+
+// Protect declares keys in ctx that With and WithC will not overwrite.
+// Use it after the trusted values have been bound, typically in middleware.
+func Protect(ctx context.Context, p ProtectedKeys) context.Context {
+	return context.WithValue(ctx, ctxProtectedKey{}, &p)
+}
+
+// MiddlewareProtectKeys applies Protect to every request. Install it after the attach middleware.
+func MiddlewareProtectKeys(p ProtectedKeys) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			c.SetRequest(req.WithContext(Protect(req.Context(), p)))
+			return next(c)
+		}
+	}
+}
+
+func (p *ProtectedKeys) protects(key string) bool {
+	return p != nil && slices.Contains(p.Keys, key)
+}
+
+// apply returns what becomes of an attempt to set a, and false if it is dropped.
+func (p *ProtectedKeys) apply(a slog.Attr) (slog.Attr, bool) {
+	if p.Action == ProtectReject {
+		return a, false
+	}
+	prefix := p.Prefix
+	if prefix == "" {
+		prefix = "user."
+	}
+	a.Key = prefix + a.Key
+	return a, true
+}
+
+// diagnostic describes a blocked attempt to set key.
+func (p *ProtectedKeys) diagnostic(key string, renamed slog.Attr, kept bool) slog.Record {
+	r := slog.NewRecord(time.Now(), slog.LevelDebug, "xlog: protected key override", 0)
+	r.AddAttrs(slog.String("protected_key", key))
+	if kept {
+		r.AddAttrs(slog.String("action", "renamed"), slog.String("renamed_to", renamed.Key))
+	} else {
+		r.AddAttrs(slog.String("action", "rejected"))
+	}
+	return r
+}
+
+// protectAttrs filters attrs against the keys protected in ctx.
+func protectAttrs(ctx context.Context, attrs []slog.Attr) []slog.Attr {
+	p, _ := ctx.Value(ctxProtectedKey{}).(*ProtectedKeys)
+	if p == nil {
+		return attrs
+	}
+	out := attrs[:0:0]
+	for _, a := range attrs {
+		if !p.protects(a.Key) {
+			out = append(out, a)
+			continue
+		}
+		na, kept := p.apply(a)
+		if h := baseLogger(ctx).Handler(); h.Enabled(ctx, slog.LevelDebug) {
+			_ = h.Handle(ctx, p.diagnostic(a.Key, na, kept))
+		}
+		if kept {
+			out = append(out, na)
+		}
+	}
+	return out
+}
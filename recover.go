@@ -0,0 +1,121 @@
+package xlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// RecoverConfig controls MiddlewareRecover.
+type RecoverConfig struct {
+	// Skipper skips the middleware for matching requests.
+	Skipper middleware.Skipper
+	// HTTPSkipper is Skipper for HTTPRecover.
+	HTTPSkipper HTTPSkipper
+
+	// RePanic re-raises the panic after it has been logged, e.g. to crash loudly in development.
+	RePanic bool
+
+	// MaxFrames limits the number of logged stack frames. Defaults to 32.
+	MaxFrames int
+}
+
+// DefaultRecoverConfig is a production friendly preset for MiddlewareRecover.
+var DefaultRecoverConfig = RecoverConfig{
+	MaxFrames: 32,
+}
+
+// MiddlewareRecover recovers panics in later handlers, logs them at error level through
+// the request logger (FromContext) with a structured stack, and responds with a generic
+// 500 problem carrying only the request id.
+//
+// http.ErrAbortHandler is re-raised untouched, as net/http expects.
+func MiddlewareRecover(config RecoverConfig) echo.MiddlewareFunc {
+	config = config.withDefaults()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			res := c.Response()
+			defer config.recoverPanic(res, c.Request, func() bool { return res.Committed })
+			return next(c)
+		}
+	}
+}
+
+// recoverPanic is the core of MiddlewareRecover and HTTPRecover and must be deferred.
+// It recovers a panic of the handler serving req(), logs it and answers through w with
+// a 500 problem, unless written reports that a response has been started.
+func (config RecoverConfig) recoverPanic(w http.ResponseWriter, req func() *http.Request, written func() bool) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	if p == http.ErrAbortHandler {
+		panic(p)
+	}
+
+	pcs := callers(1, config.MaxFrames)
+	r := req()
+	ctx, reqID := DefaultRequestIDConfig.withDefaults().resolve(r.Context(), r, w.Header())
+	ctx = panicContext(ctx, requestTenant(ctx, r), reqID)
+	logPanic(ctx, p, pcs)
+
+	if config.RePanic {
+		panic(p)
+	}
+	if !written() {
+		_ = writeProblemHTTP(w, r, PublicError{Status: http.StatusInternalServerError}, reqID)
+	}
+}
+
+func (config RecoverConfig) withDefaults() RecoverConfig {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.HTTPSkipper == nil {
+		config.HTTPSkipper = defaultHTTPSkipper
+	}
+	if config.MaxFrames <= 0 {
+		config.MaxFrames = DefaultRecoverConfig.MaxFrames
+	}
+	return config
+}
+
+// hasContextLogger reports whether a logger has been stored with ToContext.
+func hasContextLogger(ctx context.Context) bool {
+	l, ok := ctx.Value(ctxLoggerKey{}).(*slog.Logger)
+	return ok && l != nil
+}
+
+// panicContext makes sure the panic is logged with the tenant and request id, binding
+// them if no request logger has been attached yet.
+func panicContext(ctx context.Context, tenant, reqID string) context.Context {
+	if hasContextLogger(ctx) {
+		return ctx
+	}
+	return ToContext(ctx, slog.New(withTrustedAttrs(FromContext(ctx).Handler(), []slog.Attr{
+		slog.String(string(CtxTenantKey), tenant),
+		slog.String(string(CtxReqIDKey), reqID),
+	})))
+}
+
+func logPanic(ctx context.Context, p any, pcs []uintptr) {
+	FromContext(ctx).LogAttrs(ctx, slog.LevelError, "PANIC",
+		slog.String("panic", panicMessage(p)),
+		stackAttr(pcs),
+	)
+}
+
+func panicMessage(p any) string {
+	if err, ok := p.(error); ok {
+		return err.Error()
+	}
+	return fmt.Sprint(p)
+}
@@ -0,0 +1,56 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_MiddlewareRecover_LogsStructuredStack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.Use(MiddlewareRecover(DefaultRecoverConfig))
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.GET("/", func(c echo.Context) error {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?tenantId=acme", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	var body Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if body.RequestID == "" || body.RequestID != rec.Header().Get(echo.HeaderXRequestID) {
+		t.Errorf("expected body to carry the request id, got %+v", body)
+	}
+
+	var logged struct {
+		Msg       string  `json:"msg"`
+		Panic     string  `json:"panic"`
+		Tenant    string  `json:"tenant"`
+		RequestID string  `json:"request_id"`
+		Stack     []Frame `json:"stack"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &logged); err != nil {
+		t.Fatalf("unmarshal log: %v\n%s", err, buf.String())
+	}
+	if logged.Panic != "boom" || logged.Tenant != "acme" || logged.RequestID != body.RequestID {
+		t.Errorf("unexpected log record: %+v", logged)
+	}
+	if len(logged.Stack) == 0 || !strings.Contains(logged.Stack[0].Function, "Test_MiddlewareRecover") {
+		t.Errorf("expected the stack to start at the panicking handler, got %+v", logged.Stack)
+	}
+}
@@ -0,0 +1,158 @@
+package xlog
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// NameList is an allowlist and a denylist of header or query param names, compared
+// case-insensitively.
+type NameList struct {
+	// Allow lists the names that are logged. "*" allows every name.
+	Allow []string
+	// Deny lists names whose values are always logged as [REDACTED], even if allowed.
+	Deny []string
+}
+
+// value returns what is logged for name, and false if it is not logged at all.
+func (l NameList) value(name, v string) (string, bool) {
+	switch {
+	case containsFold(l.Deny, name):
+		return redacted, true
+	case slices.Contains(l.Allow, "*"), containsFold(l.Allow, name):
+		return v, true
+	default:
+		return "", false
+	}
+}
+
+// RedactPolicy is the one place that defines which headers and query params are safe
+// to log. It is used by the request loggers and by Transport.
+type RedactPolicy struct {
+	RequestHeaders  NameList
+	ResponseHeaders NameList
+	Query           NameList
+}
+
+var (
+	DefaultRedactedQuery = []string{
+		"access_token", "api_key", "apikey", "code", "key", "password", "secret", "sig", "signature", "token",
+	}
+	DefaultRedactedHeaders = []string{
+		"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie", "X-Api-Key",
+	}
+)
+
+// DefaultRedactPolicy logs a few common headers and every query param except the
+// usual secrets.
+var DefaultRedactPolicy = RedactPolicy{
+	RequestHeaders: NameList{
+		Allow: []string{"Accept", "Content-Length", "Content-Type", "Traceparent", "User-Agent", "X-Forwarded-For", "X-Request-ID"},
+		Deny:  DefaultRedactedHeaders,
+	},
+	ResponseHeaders: NameList{
+		Allow: []string{"Cache-Control", "Content-Length", "Content-Type", "Location", "Retry-After"},
+		Deny:  DefaultRedactedHeaders,
+	},
+	Query: NameList{
+		Allow: []string{"*"},
+		Deny:  DefaultRedactedQuery,
+	},
+}
+
+// httpAttr returns the allowed headers and query params as the nested group
+// http.request.header.*, http.request.query.* and http.response.header.*.
+// Header names are lower-cased. Either header may be nil.
+func (p *RedactPolicy) httpAttr(reqHeader http.Header, rawQuery string, respHeader http.Header) slog.Attr {
+	request := make([]any, 0, 2)
+	if a, ok := headerGroup(p.RequestHeaders, reqHeader); ok {
+		request = append(request, a)
+	}
+	if a, ok := p.queryGroup(rawQuery); ok {
+		request = append(request, a)
+	}
+
+	groups := make([]any, 0, 2)
+	if len(request) > 0 {
+		groups = append(groups, slog.Group("request", request...))
+	}
+	if a, ok := headerGroup(p.ResponseHeaders, respHeader); ok {
+		groups = append(groups, slog.Group("response", a))
+	}
+	return slog.Group("http", groups...)
+}
+
+func headerGroup(l NameList, h http.Header) (slog.Attr, bool) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	attrs := make([]any, 0, len(keys))
+	for _, k := range keys {
+		if v, ok := l.value(k, strings.Join(h[k], ", ")); ok {
+			attrs = append(attrs, slog.String(strings.ToLower(k), v))
+		}
+	}
+	return slog.Group("header", attrs...), len(attrs) > 0
+}
+
+func (p *RedactPolicy) queryGroup(rawQuery string) (slog.Attr, bool) {
+	q, _ := url.ParseQuery(rawQuery)
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	attrs := make([]any, 0, len(keys))
+	for _, k := range keys {
+		if v, ok := p.Query.value(k, strings.Join(q[k], ", ")); ok {
+			attrs = append(attrs, slog.String(k, v))
+		}
+	}
+	return slog.Group("query", attrs...), len(attrs) > 0
+}
+
+// redactQuery replaces the values of params that are not allowed with [REDACTED],
+// leaving the rest of the query byte for byte as it was. Bare params such as "flag"
+// have no value to hide and are kept unless denied.
+func (p *RedactPolicy) redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		k, v, hasValue := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(k)
+		if err != nil {
+			name = k
+		}
+		if !hasValue && !containsFold(p.Query.Deny, name) {
+			continue
+		}
+		if nv, ok := p.Query.value(name, v); !ok || nv == redacted {
+			pairs[i] = k + "=" + url.QueryEscape(redacted)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactURI is redactQuery for a request URI such as /path?a=b, or a full URL.
+func (p *RedactPolicy) redactURI(uri string) string {
+	path, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	return path + "?" + p.redactQuery(query)
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(v string) bool { return strings.EqualFold(v, s) })
+}
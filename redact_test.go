@@ -0,0 +1,79 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_RequestLogger_LogsAllowedHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.Use(MiddlewareRequestLoggerWithConfig(RequestLoggerConfig{LogURI: true, LogReferer: true, LogHeaders: true}))
+	e.GET("/", func(c echo.Context) error {
+		c.Response().Header().Set("Set-Cookie", "session=abc")
+		c.Response().Header().Set("X-Internal", "hidden")
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/?page=2&token=s3cret", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set("Accept", "text/plain")
+	req.Header.Set("X-Internal", "hidden")
+	req.Header.Set("Referer", "https://app.example.com/reset?token=s3cret&step=2")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	var logged struct {
+		URI     string `json:"uri"`
+		Referer string `json:"referer"`
+		HTTP    struct {
+			Request struct {
+				Header map[string]string `json:"header"`
+				Query  map[string]string `json:"query"`
+			} `json:"request"`
+			Response struct {
+				Header map[string]string `json:"header"`
+			} `json:"response"`
+		} `json:"http"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &logged); err != nil {
+		t.Fatalf("unmarshal log: %v\n%s", err, buf.String())
+	}
+
+	if logged.URI != "/?page=2&token=%5BREDACTED%5D" {
+		t.Errorf("expected the token to be redacted in the uri, got %q", logged.URI)
+	}
+	if logged.Referer != "https://app.example.com/reset?token=%5BREDACTED%5D&step=2" {
+		t.Errorf("expected the token to be redacted in the referer, got %q", logged.Referer)
+	}
+	wantReq := map[string]string{"accept": "text/plain", "authorization": redacted}
+	if !reflect.DeepEqual(logged.HTTP.Request.Header, wantReq) {
+		t.Errorf("request headers: got %v, want %v", logged.HTTP.Request.Header, wantReq)
+	}
+	wantQuery := map[string]string{"page": "2", "token": redacted}
+	if !reflect.DeepEqual(logged.HTTP.Request.Query, wantQuery) {
+		t.Errorf("query: got %v, want %v", logged.HTTP.Request.Query, wantQuery)
+	}
+	wantResp := map[string]string{"content-type": "text/plain; charset=UTF-8", "set-cookie": redacted}
+	if !reflect.DeepEqual(logged.HTTP.Response.Header, wantResp) {
+		t.Errorf("response headers: got %v, want %v", logged.HTTP.Response.Header, wantResp)
+	}
+}
+
+func Test_RedactPolicy_redactQuery(t *testing.T) {
+	p := &RedactPolicy{Query: NameList{Allow: []string{"b", "Token"}, Deny: []string{"token"}}}
+	got := p.redactQuery("b=x%20y&a=1&TOKEN=t&b=2&flag&token")
+	want := "b=x%20y&a=%5BREDACTED%5D&TOKEN=%5BREDACTED%5D&b=2&flag&token=%5BREDACTED%5D"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,302 @@
+package xlog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// RequestLoggerConfig controls the final per-request log line.
+type RequestLoggerConfig struct {
+	// Skipper skips logging for matching requests.
+	Skipper middleware.Skipper
+	// HTTPSkipper is Skipper for HTTPRequestLogger.
+	HTTPSkipper HTTPSkipper
+
+	// Field selection for the final line.
+	LogStatus    bool
+	LogLatency   bool
+	LogURI       bool
+	LogHost      bool
+	LogRemoteIP  bool
+	LogUserAgent bool
+	LogReferer   bool
+	// ParseUserAgent logs "user_agent" as a group with the browser, its version, the OS,
+	// the device class and a bot flag instead of the raw string, see ParseUserAgent.
+	// KeepRawUserAgent adds the raw string to the group as "raw".
+	ParseUserAgent   bool
+	KeepRawUserAgent bool
+	// LogRoute logs the matched route pattern (c.Path(), e.g. /users/:id) as "route".
+	// Unlike the raw path it has low cardinality, which keeps aggregations sane.
+	LogRoute bool
+	// LogSizes logs the request and response body sizes as "bytes_in" and "bytes_out".
+	// bytes_in counts what the handler read, so it also covers chunked uploads.
+	LogSizes bool
+	// LogHeaders logs the allowed request headers, query params and response headers
+	// as the http group, see RedactPolicy.
+	LogHeaders bool
+
+	// IPPolicy resolves and anonymizes "remote_ip". By default the connection address is
+	// logged, or on echo the IPExtractor's result if one is set. Use the same policy as the
+	// attach middleware, see WithIPPolicy.
+	IPPolicy *IPPolicy
+
+	// Redact decides what LogHeaders logs, and redacts query params in "uri" and
+	// "referer" that it does not allow. Defaults to DefaultRedactPolicy.
+	Redact *RedactPolicy
+
+	// LevelFunc maps the outcome of a request to a level. Defaults to StatusLevel.
+	LevelFunc func(status int, err error) slog.Level
+
+	// Message is used for requests without an error, ErrorMessage for the rest.
+	// Defaults to "REQUEST" and "REQUEST_ERROR".
+	Message      string
+	ErrorMessage string
+
+	// SlowThreshold marks requests taking at least this long with slow=true and
+	// raises their level to SlowLevel. Zero disables the check.
+	SlowThreshold time.Duration
+	// SlowRoutes overrides SlowThreshold per route pattern (as returned by c.Path()).
+	SlowRoutes map[string]time.Duration
+	// SlowLevel is the minimum level of a slow request. Defaults to slog.LevelWarn.
+	SlowLevel slog.Leveler
+}
+
+// DefaultRequestLoggerConfig is the preset used by MiddlewareRequestLoggerSlog.
+var DefaultRequestLoggerConfig = RequestLoggerConfig{
+	LogStatus:    true,
+	LogLatency:   true,
+	LogURI:       true,
+	LogHost:      true,
+	LogRemoteIP:  true,
+	LogUserAgent: true,
+	LogReferer:   true,
+}
+
+// StatusLevel is the default LevelFunc: 5xx are errors, 4xx warnings and everything else info.
+// An error that did not produce an error status is still logged as an error.
+func StatusLevel(status int, err error) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	case err != nil:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// requestValues is the framework independent view of a finished request.
+type requestValues struct {
+	Status    int
+	Err       error
+	Latency   time.Duration
+	URI       string
+	Route     string
+	Host      string
+	RemoteIP  string
+	UserAgent string
+	Referer   string
+	BytesIn   int64
+	BytesOut  int64
+
+	Header         http.Header
+	ResponseHeader http.Header
+}
+
+// servedRequest is what a framework adapter reports to serve once a request has been handled.
+type servedRequest struct {
+	// Request is the request as last seen, carrying context changes made further in.
+	Request  *http.Request
+	Route    string
+	RemoteIP string
+	Err      error
+}
+
+// serve is the core of MiddlewareRequestLoggerWithConfig and HTTPRequestLogger: it lets
+// next handle r through a recorder for w, then logs the request.
+func (config RequestLoggerConfig) serve(w http.ResponseWriter, r *http.Request, next func(w http.ResponseWriter, r *http.Request) servedRequest) {
+	start := time.Now()
+	rec := newResponseRecorder(w)
+	body := &countingBody{ReadCloser: r.Body}
+	if r.Body != nil && r.Body != http.NoBody {
+		r = r.WithContext(r.Context())
+		r.Body = body
+	}
+	s := next(rec, r)
+
+	config.log(s.Request.Context(), requestValues{
+		Status:    rec.Status(),
+		Err:       s.Err,
+		Latency:   time.Since(start),
+		URI:       s.Request.RequestURI,
+		Route:     s.Route,
+		Host:      s.Request.Host,
+		RemoteIP:  config.remoteIP(s.Request, s.RemoteIP),
+		UserAgent: s.Request.UserAgent(),
+		Referer:   s.Request.Referer(),
+		BytesIn:   body.n,
+		BytesOut:  rec.bytes,
+
+		Header:         s.Request.Header,
+		ResponseHeader: rec.Header(),
+	})
+}
+
+// countingBody counts the bytes read from a request body, like responseRecorder does
+// for the response.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// MiddlewareRequestLoggerWithConfig logs one line per request according to config.
+// Errors of later handlers go through the echo error handler first, so that the
+// logged status is the one sent, and are then returned as echo's RequestLogger does.
+func MiddlewareRequestLoggerWithConfig(config RequestLoggerConfig) echo.MiddlewareFunc {
+	config = config.withDefaults()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			res := c.Response()
+			config.serve(res.Writer, c.Request(), func(w http.ResponseWriter, r *http.Request) servedRequest {
+				res.Writer = w
+				c.SetRequest(r)
+				if err = next(c); err != nil {
+					c.Error(err)
+				}
+				return servedRequest{Request: c.Request(), Route: c.Path(), RemoteIP: echoRemoteIP(c, nil), Err: err}
+			})
+			return err
+		}
+	}
+}
+
+func (config RequestLoggerConfig) withDefaults() RequestLoggerConfig {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.HTTPSkipper == nil {
+		config.HTTPSkipper = defaultHTTPSkipper
+	}
+	if config.LevelFunc == nil {
+		config.LevelFunc = StatusLevel
+	}
+	if config.Message == "" {
+		config.Message = "REQUEST"
+	}
+	if config.ErrorMessage == "" {
+		config.ErrorMessage = "REQUEST_ERROR"
+	}
+	if config.SlowLevel == nil {
+		config.SlowLevel = slog.LevelWarn
+	}
+	if config.Redact == nil {
+		config.Redact = &DefaultRedactPolicy
+	}
+	return config
+}
+
+// remoteIP returns the client IP of r according to the IP policy, or fallback without one.
+func (config RequestLoggerConfig) remoteIP(r *http.Request, fallback string) string {
+	if config.IPPolicy != nil {
+		return config.IPPolicy.ClientIP(r)
+	}
+	return fallback
+}
+
+// slowThreshold returns the threshold that applies to route, or 0 if none does.
+func (config RequestLoggerConfig) slowThreshold(route string) time.Duration {
+	if d, ok := config.SlowRoutes[route]; ok {
+		return d
+	}
+	return config.SlowThreshold
+}
+
+func (config RequestLoggerConfig) log(ctx context.Context, v requestValues) {
+	level := config.LevelFunc(v.Status, v.Err)
+
+	attrs := make([]slog.Attr, 0, 12)
+	if config.LogStatus {
+		attrs = append(attrs, slog.Int("status", v.Status))
+	}
+	if config.LogLatency {
+		attrs = append(attrs, slog.Int64("duration_ms", v.Latency.Milliseconds()))
+	}
+	if config.LogURI {
+		attrs = append(attrs, slog.String("uri", config.Redact.redactURI(v.URI)))
+	}
+	if config.LogRoute {
+		attrs = append(attrs, slog.String("route", v.Route))
+	}
+	if config.LogUserAgent {
+		if config.ParseUserAgent {
+			attrs = append(attrs, DefaultUserAgentParser.Parse(v.UserAgent).attr(v.UserAgent, config.KeepRawUserAgent))
+		} else {
+			attrs = append(attrs, slog.String("user_agent", v.UserAgent))
+		}
+	}
+	if config.LogHost {
+		attrs = append(attrs, slog.String("host", v.Host))
+	}
+	if config.LogRemoteIP && v.RemoteIP != "" {
+		attrs = append(attrs, slog.String("remote_ip", v.RemoteIP))
+	}
+	if config.LogReferer {
+		attrs = append(attrs, slog.String("referer", config.Redact.redactURI(v.Referer)))
+	}
+	if config.LogSizes {
+		attrs = append(attrs,
+			slog.Int64("bytes_in", v.BytesIn),
+			slog.Int64("bytes_out", v.BytesOut),
+		)
+	}
+
+	if config.LogHeaders {
+		_, query, _ := strings.Cut(v.URI, "?")
+		attrs = append(attrs, config.Redact.httpAttr(v.Header, query, v.ResponseHeader))
+	}
+
+	if threshold := config.slowThreshold(v.Route); threshold > 0 && v.Latency >= threshold {
+		attrs = append(attrs, slog.Bool("slow", true))
+		level = max(level, config.SlowLevel.Level())
+	}
+
+	// Decide tail sampling before logging, so this line itself goes straight through.
+	sampled, ok := finishSampling(ctx, v.Status, v.Err, v.Latency)
+	if ok {
+		attrs = append(attrs, slog.Bool("sampled", sampled))
+	}
+
+	// Bodies are only worth their size when someone will look at the request.
+	logger := FromContext(ctx)
+	if v.Err != nil || v.Status >= 400 || sampled || logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, bodyAttrs(ctx)...)
+	}
+
+	msg := config.Message
+	if v.Err != nil {
+		msg = config.ErrorMessage
+		attrs = append(attrs, Err(v.Err))
+	}
+
+	// Pulls the logger from context to include any attached values, such as the request id.
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,119 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_RequestLogger_LevelsRouteAndSlow(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	e := echo.New()
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.Use(MiddlewareRequestLoggerWithConfig(RequestLoggerConfig{
+		LogStatus:  true,
+		LogRoute:   true,
+		LogSizes:   true,
+		Message:    "done",
+		SlowRoutes: map[string]time.Duration{"/slow/:id": time.Millisecond},
+	}))
+	e.GET("/missing/:id", func(c echo.Context) error {
+		return c.String(http.StatusNotFound, "nope")
+	})
+	e.GET("/slow/:id", func(c echo.Context) error {
+		time.Sleep(2 * time.Millisecond)
+		return c.String(http.StatusOK, "ok")
+	})
+
+	cases := []struct {
+		path  string
+		level string
+		slow  bool
+	}{
+		{"/missing/1", "WARN", false},
+		{"/slow/1", "WARN", true},
+	}
+	for _, tc := range cases {
+		buf.Reset()
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		var rec map[string]any
+		if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &rec); err != nil {
+			t.Fatalf("unmarshal: %v\n%s", err, buf.String())
+		}
+		if rec["msg"] != "done" || rec["level"] != tc.level {
+			t.Errorf("%s: want msg=done level=%s, got %v", tc.path, tc.level, rec)
+		}
+		if _, ok := rec["route"].(string); !ok {
+			t.Errorf("%s: expected route, got %v", tc.path, rec)
+		}
+		if _, ok := rec["bytes_out"]; !ok {
+			t.Errorf("%s: expected bytes_out, got %v", tc.path, rec)
+		}
+		if slow, _ := rec["slow"].(bool); slow != tc.slow {
+			t.Errorf("%s: want slow=%v, got %v", tc.path, tc.slow, rec["slow"])
+		}
+	}
+}
+
+func Test_RequestLogger_ErrorStatusAndRemoteIP(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.HTTPErrorHandler = func(err error, c echo.Context) { _ = c.NoContent(http.StatusTeapot) }
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.Use(MiddlewareRequestLoggerWithConfig(RequestLoggerConfig{LogStatus: true, LogRemoteIP: true}))
+	e.GET("/", func(c echo.Context) error { return echo.ErrBadRequest })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	var rec map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &rec); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, buf.String())
+	}
+	if rec["status"] != float64(http.StatusTeapot) || rec["msg"] != "REQUEST_ERROR" {
+		t.Errorf("expected the status sent by the error handler, got %v", rec)
+	}
+	if rec["remote_ip"] != "192.0.2.1" {
+		t.Errorf("expected the connection address without an IP extractor, got %v", rec["remote_ip"])
+	}
+}
+
+func Test_RequestLogger_CountsBytesRead(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.Use(MiddlewareRequestLoggerWithConfig(RequestLoggerConfig{LogSizes: true}))
+	e.POST("/", func(c echo.Context) error {
+		_, _ = io.Copy(io.Discard, c.Request().Body)
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("chunked body"))
+	req.ContentLength = -1 // unknown length, as for a chunked upload
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	var rec map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &rec); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, buf.String())
+	}
+	if rec["bytes_in"] != float64(len("chunked body")) {
+		t.Errorf("expected bytes_in to count the bytes read, got %v", rec["bytes_in"])
+	}
+}
@@ -0,0 +1,158 @@
+package xlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// RequestIDConfig controls how MiddlewareRequestID accepts or generates request ids.
+type RequestIDConfig struct {
+	// Skipper skips the middleware for matching requests.
+	Skipper middleware.Skipper
+	// HTTPSkipper is Skipper for HTTPRequestID.
+	HTTPSkipper HTTPSkipper
+
+	// Headers are checked in order for an inbound id. Defaults to X-Request-ID.
+	Headers []string
+	// ResponseHeader is set to the resolved id. Defaults to X-Request-ID.
+	ResponseHeader string
+
+	// MaxLength is the longest inbound id that is accepted. Defaults to 128.
+	MaxLength int
+	// Validator decides whether an inbound id is acceptable. Defaults to ValidRequestID.
+	Validator func(id string) bool
+	// Generator creates ids when no acceptable inbound id is present. Defaults to NewRequestID.
+	Generator func() string
+}
+
+// DefaultRequestIDConfig is used by MiddlewareRequestID and by the attach middlewares
+// when no request id has been resolved yet.
+var DefaultRequestIDConfig = RequestIDConfig{
+	Headers:        []string{echo.HeaderXRequestID},
+	ResponseHeader: echo.HeaderXRequestID,
+	MaxLength:      128,
+}
+
+// MiddlewareRequestID resolves the request id once per request: an acceptable inbound id
+// is reused, otherwise a new one is generated. The id is set on the response and stored
+// in the request info, see RequestID.
+func MiddlewareRequestID(config RequestIDConfig) echo.MiddlewareFunc {
+	config = config.withDefaults()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			req := c.Request()
+			ctx, _ := config.resolve(req.Context(), req, c.Response().Header())
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+func (config RequestIDConfig) withDefaults() RequestIDConfig {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.HTTPSkipper == nil {
+		config.HTTPSkipper = defaultHTTPSkipper
+	}
+	if len(config.Headers) == 0 {
+		config.Headers = DefaultRequestIDConfig.Headers
+	}
+	if config.ResponseHeader == "" {
+		config.ResponseHeader = DefaultRequestIDConfig.ResponseHeader
+	}
+	if config.MaxLength <= 0 {
+		config.MaxLength = DefaultRequestIDConfig.MaxLength
+	}
+	if config.Validator == nil {
+		config.Validator = ValidRequestID
+	}
+	if config.Generator == nil {
+		config.Generator = NewRequestID
+	}
+	return config
+}
+
+// resolve returns ctx carrying the request id for r. An id already in ctx or already set
+// on the response, e.g. by echo's middleware.RequestID, is kept as is.
+func (config RequestIDConfig) resolve(ctx context.Context, r *http.Request, respHeader http.Header) (context.Context, string) {
+	if id := RequestID(ctx); id != "" {
+		return ctx, id
+	}
+	if id := respHeader.Get(config.ResponseHeader); id != "" {
+		return UpdateRequestInfo(ctx, func(info *RequestInfo) { info.RequestID = id }), id
+	}
+
+	id := ""
+	for _, h := range config.Headers {
+		if v := r.Header.Get(h); v != "" && len(v) <= config.MaxLength && config.Validator(v) {
+			id = v
+			break
+		}
+	}
+	if id == "" {
+		id = config.Generator()
+	}
+
+	respHeader.Set(config.ResponseHeader, id)
+	return UpdateRequestInfo(ctx, func(info *RequestInfo) { info.RequestID = id }), id
+}
+
+// ensureRequestID makes sure the echo request carries a request id and returns it.
+func ensureRequestID(c echo.Context) string {
+	req := c.Request()
+	ctx, id := DefaultRequestIDConfig.withDefaults().resolve(req.Context(), req, c.Response().Header())
+	if ctx != req.Context() {
+		c.SetRequest(req.WithContext(ctx))
+	}
+	return id
+}
+
+// ValidRequestID reports whether id is non-empty and only uses letters, digits and -_.:
+func ValidRequestID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch ch := id[i]; {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.', ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Crockford base32, as used by ULID.
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewRequestID returns a 26 character ULID: a millisecond timestamp followed by 80 random bits.
+// Ids sort lexically by creation time.
+func NewRequestID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	_, _ = rand.Read(b[6:])
+
+	// 128 bits encoded 5 bits at a time, most significant first; the first
+	// character only carries the top 3 bits.
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = ulidAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
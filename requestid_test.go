@@ -0,0 +1,90 @@
+package xlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+func Test_RequestID_InboundAndGenerated(t *testing.T) {
+	e := echo.New()
+	e.Use(MiddlewareRequestID(RequestIDConfig{Headers: []string{"X-Correlation-ID", echo.HeaderXRequestID}}))
+
+	var got string
+	e.GET("/", func(c echo.Context) error {
+		got = RequestID(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"inbound", map[string]string{"X-Correlation-ID": "abc-123"}, "abc-123"},
+		{"fallback header", map[string]string{echo.HeaderXRequestID: "req.1"}, "req.1"},
+		{"invalid charset", map[string]string{echo.HeaderXRequestID: "bad id<script>"}, ""},
+		{"missing", nil, ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if tc.want != "" && got != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.name, tc.want, got)
+		}
+		if tc.want == "" && len(got) != 26 {
+			t.Errorf("%s: expected a generated id, got %q", tc.name, got)
+		}
+		if h := rec.Header().Get(echo.HeaderXRequestID); h != got {
+			t.Errorf("%s: response header %q does not match %q", tc.name, h, got)
+		}
+	}
+}
+
+func Test_NewRequestID_Sortable(t *testing.T) {
+	ids := make([]string, 0, 3)
+	for range 3 {
+		ids = append(ids, NewRequestID())
+		time.Sleep(2 * time.Millisecond)
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("expected ids to sort by creation time: %v", ids)
+	}
+	for _, id := range ids {
+		if !ValidRequestID(id) {
+			t.Errorf("generated id %q is not valid", id)
+		}
+	}
+}
+
+func Test_RequestID_KeepsEchoRequestID(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.Use(MiddlewareRequestID(DefaultRequestIDConfig))
+
+	var got string
+	e.GET("/", func(c echo.Context) error {
+		got = RequestID(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if h := rec.Header().Get(echo.HeaderXRequestID); got == "" || h != got {
+		t.Errorf("expected echo's id %q to be kept, got %q", h, got)
+	}
+	if len(got) == 26 {
+		t.Errorf("expected echo's generated id rather than a ULID, got %q", got)
+	}
+}
@@ -0,0 +1,163 @@
+package xlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestInfo is the per-request metadata written once by the attach middlewares and
+// read by the extractors, the middlewares in this package and any non-logging code
+// that needs the tenant or request id.
+type RequestInfo struct {
+	Tenant    string
+	RequestID string
+	Method    string
+	Path      string
+	// Route is the matched route pattern, e.g. /users/:id.
+	Route    string
+	User     string
+	RemoteIP string
+	Start    time.Time
+	// TraceParent is the valid W3C traceparent header of the request, if any, which
+	// Transport continues on outbound calls.
+	TraceParent string
+}
+
+// Key for the request info
+type ctxRequestInfoKey struct{}
+
+// requestInfoValue is what is stored in the context. The attrs are built once so that
+// extracting them on every log call does not allocate.
+type requestInfoValue struct {
+	info  RequestInfo
+	attrs []slog.Attr
+}
+
+// RequestInfoToContext returns ctx carrying a copy of info.
+func RequestInfoToContext(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, ctxRequestInfoKey{}, &requestInfoValue{
+		info:  info,
+		attrs: info.Attrs(),
+	})
+}
+
+// RequestInfoFromContext returns the request info stored in ctx.
+func RequestInfoFromContext(ctx context.Context) (RequestInfo, bool) {
+	if v := requestInfoFromContext(ctx); v != nil {
+		return v.info, true
+	}
+	return RequestInfo{}, false
+}
+
+// UpdateRequestInfo returns ctx carrying the stored request info (or a zero one) as changed by fn.
+func UpdateRequestInfo(ctx context.Context, fn func(info *RequestInfo)) context.Context {
+	info, _ := RequestInfoFromContext(ctx)
+	fn(&info)
+	return RequestInfoToContext(ctx, info)
+}
+
+func requestInfoFromContext(ctx context.Context) *requestInfoValue {
+	v, _ := ctx.Value(ctxRequestInfoKey{}).(*requestInfoValue)
+	return v
+}
+
+// Attrs returns the attrs logged for every record of the request: tenant, request_id,
+// method and path, plus user once it is known.
+func (info RequestInfo) Attrs() []slog.Attr {
+	attrs := make([]slog.Attr, 0, 5)
+	attrs = append(attrs,
+		slog.String(string(CtxTenantKey), info.Tenant),
+		slog.String(string(CtxReqIDKey), info.RequestID),
+		slog.String(string(CtxMethodKey), info.Method),
+		slog.String(string(CtxURIPathKey), info.Path),
+	)
+	if info.User != "" {
+		attrs = append(attrs, slog.String(string(CtxUserKey), info.User))
+	}
+	return attrs
+}
+
+// RequestInfoArgs is an XlogHandler extractor for the request info in ctx.
+func RequestInfoArgs(ctx context.Context) []slog.Attr {
+	if v := requestInfoFromContext(ctx); v != nil {
+		return v.attrs
+	}
+	return nil
+}
+
+// Tenant returns the tenant of the request in ctx, or "".
+func Tenant(ctx context.Context) string {
+	if v := requestInfoFromContext(ctx); v != nil {
+		return v.info.Tenant
+	}
+	return legacyString(ctx, CtxTenantKey)
+}
+
+// RequestID returns the request id of the request in ctx, or "".
+func RequestID(ctx context.Context) string {
+	if v := requestInfoFromContext(ctx); v != nil {
+		return v.info.RequestID
+	}
+	return legacyString(ctx, CtxReqIDKey)
+}
+
+// User returns the authenticated user of the request in ctx, or "".
+func User(ctx context.Context) string {
+	if v := requestInfoFromContext(ctx); v != nil {
+		return v.info.User
+	}
+	return legacyString(ctx, CtxUserKey)
+}
+
+// legacyString reads values stored directly under the ctxKey constants.
+func legacyString(ctx context.Context, key ctxKey) string {
+	if v := ctx.Value(key); v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// newRequestInfo completes the request info stored in ctx for r, keeping fields
+// resolved by earlier middleware such as the user.
+func newRequestInfo(ctx context.Context, r *http.Request, tenant, reqID, route, remoteIP string) RequestInfo {
+	info, _ := RequestInfoFromContext(ctx)
+	info.Tenant = tenant
+	info.RequestID = reqID
+	info.Method = r.Method
+	info.Path = r.URL.Path
+	info.Route = route
+	info.RemoteIP = remoteIP
+	if tp := r.Header.Get("traceparent"); validTraceparent(tp) {
+		info.TraceParent = tp
+	}
+	if info.Start.IsZero() {
+		info.Start = time.Now()
+	}
+	return info
+}
+
+// echoRequestInfo builds the request info for c, resolving the tenant and request id if needed.
+// The client IP comes from ip if set, else from echo's RealIP when the app configured an
+// IPExtractor, else from the connection: forwarding headers are not trusted by default.
+func echoRequestInfo(c echo.Context, ip *IPPolicy) RequestInfo {
+	reqID := ensureRequestID(c)
+	req := c.Request()
+	return newRequestInfo(req.Context(), req, GetTenant(c), reqID, c.Path(), echoRemoteIP(c, ip))
+}
+
+// echoRemoteIP returns the client IP of c as described for echoRequestInfo.
+func echoRemoteIP(c echo.Context, ip *IPPolicy) string {
+	switch {
+	case ip != nil:
+		return ip.ClientIP(c.Request())
+	case c.Echo() != nil && c.Echo().IPExtractor != nil:
+		return c.RealIP()
+	default:
+		return remoteAddrIP(c.Request())
+	}
+}
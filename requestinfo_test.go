@@ -0,0 +1,68 @@
+package xlog
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_RequestInfo_SharedByAllAttachVariants(t *testing.T) {
+	variants := map[string]func(*slog.Logger, ...AttachOption) echo.MiddlewareFunc{
+		"logger": MiddlewareAttachDefaultsLogger,
+		"ctx":    MiddlewareAttachDefaultsCtx,
+		"ctxOld": MiddlewareAttachDefaultsCtxOld,
+	}
+	for name, attach := range variants {
+		var buf bytes.Buffer
+		logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil), DefaultPerRequestArgs))
+
+		e := echo.New()
+		e.Use(attach(logger))
+
+		var info RequestInfo
+		e.GET("/cards/:id", func(c echo.Context) error {
+			ctx := c.Request().Context()
+			info, _ = RequestInfoFromContext(ctx)
+			if Tenant(ctx) != "acme" || RequestID(ctx) != info.RequestID {
+				t.Errorf("%s: getters disagree with %+v", name, info)
+			}
+			// Plain slog calls get the same attrs as the xlog helpers.
+			logger.InfoContext(ctx, "plain")
+			if !hasContextLogger(ctx) {
+				ctx = ToContext(ctx, logger)
+			}
+			Info(ctx, "helper")
+			return c.NoContent(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/cards/7?tenantId=acme", nil)
+		req.Header.Set(echo.HeaderXRequestID, "req-7")
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		if info.Tenant != "acme" || info.RequestID != "req-7" || info.Route != "/cards/:id" ||
+			info.Path != "/cards/7" || info.Start.IsZero() {
+			t.Errorf("%s: unexpected request info %+v", name, info)
+		}
+		if info.RemoteIP != "192.0.2.1" {
+			t.Errorf("%s: expected the connection address without an IP policy, got %q", name, info.RemoteIP)
+		}
+
+		// Checked on the raw lines: a duplicate key would be hidden by json.Unmarshal.
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("%s: expected 2 lines, got %s", name, buf.String())
+		}
+		for _, line := range lines {
+			if strings.Count(line, `"tenant":"acme"`) != 1 || strings.Count(line, `"request_id":"req-7"`) != 1 ||
+				strings.Count(line, `"tenant"`) != 1 {
+				t.Errorf("%s: expected the request attrs exactly once, got %s", name, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TailSampler decides at the end of a request whether all of its records are emitted
+// or all are dropped, so sampled requests keep their whole story.
+//
+// It needs a SamplingHandler in the handler chain and the attach middleware to tie
+// records to their request:
+//
+//	logger := slog.New(xlog.NewSamplingHandler(jsonHandler))
+//	e.Use(xlog.MiddlewareAttachDefaultsLogger(logger, xlog.WithTailSampling(sampler)))
+//	e.Use(xlog.MiddlewareRequestLoggerSlog())
+//
+// The final REQUEST line is always emitted and records the decision as sampled=true/false.
+type TailSampler struct {
+	// Rate is the fraction (0 to 1) of successful requests that are kept.
+	Rate float64
+	// SlowThreshold keeps requests taking at least this long. Zero disables the check.
+	SlowThreshold time.Duration
+	// Tenants are always kept.
+	Tenants []string
+	// MaxRecords caps the records buffered per request. A request that exceeds it is kept,
+	// and its records are flushed right away. Defaults to 1000.
+	MaxRecords int
+
+	// Rand returns a number in [0, 1). Defaults to math/rand/v2.Float64.
+	Rand func() float64
+}
+
+// keep decides the fate of a finished request. Errored requests (an error or a 5xx
+// status), slow requests and listed tenants are always kept.
+func (s *TailSampler) keep(ctx context.Context, status int, err error, latency time.Duration) bool {
+	switch {
+	case err != nil || status >= 500:
+		return true
+	case s.SlowThreshold > 0 && latency >= s.SlowThreshold:
+		return true
+	case len(s.Tenants) > 0 && slices.Contains(s.Tenants, Tenant(ctx)):
+		return true
+	}
+	random := s.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	return random() < s.Rate
+}
+
+// Key for the per-request record buffer.
+type ctxSampleBufferKey struct{}
+
+type bufferedRecord struct {
+	handler slog.Handler
+	ctx     context.Context
+	rec     slog.Record
+	trusted []slog.Attr
+}
+
+// sampleBuffer holds the records of one request until the sampler decides.
+type sampleBuffer struct {
+	sampler *TailSampler
+
+	mu      sync.Mutex
+	records []bufferedRecord
+	decided bool
+	sampled bool
+}
+
+// withSampleBuffer returns ctx in which records reaching a SamplingHandler are buffered.
+func withSampleBuffer(ctx context.Context, s *TailSampler) context.Context {
+	return context.WithValue(ctx, ctxSampleBufferKey{}, &sampleBuffer{sampler: s})
+}
+
+// add buffers rec, reporting false once the request has been decided and records
+// should go straight through.
+func (b *sampleBuffer) add(h slog.Handler, ctx context.Context, rec slog.Record, trusted []slog.Attr) bool {
+	b.mu.Lock()
+	if b.decided {
+		b.mu.Unlock()
+		return false
+	}
+
+	limit := b.sampler.MaxRecords
+	if limit <= 0 {
+		limit = 1000
+	}
+	if len(b.records) >= limit {
+		flush := b.decideLocked(true)
+		b.mu.Unlock()
+		emitRecords(flush)
+		return false
+	}
+	b.records = append(b.records, bufferedRecord{h, ctx, rec.Clone(), trusted})
+	b.mu.Unlock()
+	return true
+}
+
+// decideLocked records the decision and empties the buffer, returning the records to
+// emit. b.mu must be held; the records are emitted after releasing it, so that a slow
+// sink does not block the other goroutines logging for the request.
+func (b *sampleBuffer) decideLocked(sampled bool) []bufferedRecord {
+	b.decided, b.sampled = true, sampled
+	records := b.records
+	b.records = nil
+	if !sampled {
+		return nil
+	}
+	return records
+}
+
+func emitRecords(records []bufferedRecord) {
+	for _, br := range records {
+		_ = handleTrusted(br.handler, br.ctx, br.rec, br.trusted)
+	}
+}
+
+// finishSampling decides the request in ctx if that has not happened yet and returns
+// the decision. ok is false if the request is not being sampled.
+func finishSampling(ctx context.Context, status int, err error, latency time.Duration) (sampled, ok bool) {
+	b, _ := ctx.Value(ctxSampleBufferKey{}).(*sampleBuffer)
+	if b == nil {
+		return false, false
+	}
+	b.mu.Lock()
+	var flush []bufferedRecord
+	if !b.decided {
+		flush = b.decideLocked(b.sampler.keep(ctx, status, err, latency))
+	}
+	sampled = b.sampled
+	b.mu.Unlock()
+
+	emitRecords(flush)
+	return sampled, true
+}
+
+// SamplingHandler buffers the records of requests under tail sampling, see TailSampler.
+// Records outside such requests pass straight through.
+type SamplingHandler struct {
+	handler slog.Handler
+}
+
+func NewSamplingHandler(handler slog.Handler) *SamplingHandler {
+	return &SamplingHandler{handler: handler}
+}
+
+var (
+	_ slog.Handler   = (*SamplingHandler)(nil)
+	_ trustedHandler = (*SamplingHandler)(nil)
+)
+
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return levelOverridden(ctx, level) || h.handler.Enabled(ctx, level)
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, rec slog.Record) error {
+	return h.handleTrusted(ctx, rec, nil)
+}
+
+func (h *SamplingHandler) handleTrusted(ctx context.Context, rec slog.Record, trusted []slog.Attr) error {
+	if b, _ := ctx.Value(ctxSampleBufferKey{}).(*sampleBuffer); b != nil && b.add(h.handler, ctx, rec, trusted) {
+		return nil
+	}
+	return handleTrusted(h.handler, ctx, rec, trusted)
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *SamplingHandler) withTrustedAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{handler: withTrustedAttrs(h.handler, attrs)}
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{handler: h.handler.WithGroup(name)}
+}
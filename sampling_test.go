@@ -0,0 +1,110 @@
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_TailSampling_WholeRequests(t *testing.T) {
+	sampler := &TailSampler{
+		Rate:    0.5,
+		Tenants: []string{"vip"},
+		Rand:    func() float64 { return 0.9 }, // never sampled by rate
+	}
+
+	cases := []struct {
+		name    string
+		target  string
+		sampled bool
+	}{
+		{"success dropped", "/ok", false},
+		{"error kept", "/fail", true},
+		{"tenant kept", "/ok?tenantId=vip", true},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		logger := slog.New(NewSamplingHandler(slog.NewJSONHandler(&buf, nil)))
+
+		e := echo.New()
+		e.Use(MiddlewareAttachDefaultsLogger(logger, WithTailSampling(sampler)))
+		e.Use(MiddlewareRequestLoggerSlog())
+		e.GET("/ok", func(c echo.Context) error {
+			InfoC(c, "step 1")
+			InfoC(c, "step 2")
+			return c.NoContent(http.StatusOK)
+		})
+		e.GET("/fail", func(c echo.Context) error {
+			InfoC(c, "step 1")
+			return errors.New("boom")
+		})
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		var last map[string]any
+		if err := json.Unmarshal(lines[len(lines)-1], &last); err != nil {
+			t.Fatalf("%s: unmarshal: %v\n%s", tc.name, err, buf.String())
+		}
+		if last["sampled"] != tc.sampled {
+			t.Errorf("%s: want sampled=%v on the request line, got %v", tc.name, tc.sampled, last)
+		}
+		hasSteps := bytes.Contains(buf.Bytes(), []byte("step 1"))
+		if hasSteps != tc.sampled {
+			t.Errorf("%s: want request records emitted=%v, got\n%s", tc.name, tc.sampled, buf.String())
+		}
+		if !tc.sampled && len(lines) != 1 {
+			t.Errorf("%s: expected only the request line, got\n%s", tc.name, buf.String())
+		}
+	}
+}
+
+// blockingHandler blocks on records with the message "slow" until release is closed.
+type blockingHandler struct {
+	slog.Handler
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Message == "slow" {
+		close(h.entered)
+		<-h.release
+	}
+	return nil
+}
+
+func Test_TailSampling_FlushOutsideLock(t *testing.T) {
+	h := &blockingHandler{
+		Handler: slog.NewJSONHandler(io.Discard, nil),
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	defer close(h.release)
+	logger := slog.New(NewSamplingHandler(h))
+	ctx := withSampleBuffer(context.Background(), &TailSampler{Rate: 1})
+
+	logger.InfoContext(ctx, "slow")
+	go finishSampling(ctx, http.StatusOK, nil, 0)
+	<-h.entered
+
+	// The flush is stuck in the sink; other goroutines of the request must not be.
+	done := make(chan struct{})
+	go func() {
+		logger.InfoContext(ctx, "fast")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected logging to proceed while the buffer is being flushed")
+	}
+}
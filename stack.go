@@ -0,0 +1,44 @@
+package xlog
+
+import (
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+// Frame is a single resolved stack frame, logged as part of a "stack" attr.
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// callers returns up to max program counters of the calling goroutine, skipping
+// skip frames above the caller of callers.
+func callers(skip, max int) []uintptr {
+	pcs := make([]uintptr, max)
+	n := runtime.Callers(skip+2, pcs)
+	return pcs[:n]
+}
+
+// stackFrames resolves pcs, dropping the runtime frames at the top of the stack
+// (runtime.gopanic and friends when called while panicking).
+func stackFrames(pcs []uintptr) []Frame {
+	frames := runtime.CallersFrames(pcs)
+	out := make([]Frame, 0, len(pcs))
+	for {
+		f, more := frames.Next()
+		if len(out) > 0 || !strings.HasPrefix(f.Function, "runtime.") {
+			out = append(out, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return out
+}
+
+// stackAttr returns pcs as a structured "stack" attr.
+func stackAttr(pcs []uintptr) slog.Attr {
+	return slog.Any("stack", stackFrames(pcs))
+}
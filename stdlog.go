@@ -0,0 +1,203 @@
+package xlog
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewStdLogger returns a *log.Logger for code that only speaks the log package. Every
+// message becomes one record of logger at level; known net/http messages are turned
+// into structured attrs with a level of their own, see stdLogPatterns.
+func NewStdLogger(logger *slog.Logger, level slog.Level) *log.Logger {
+	return log.New(&stdLogWriter{logger: logger, level: level}, "", 0)
+}
+
+// ErrStdLogLoop is returned by RedirectStdLog for a logger that itself writes to the
+// log package, which would deadlock.
+var ErrStdLogLoop = errors.New("xlog: logger writes to the log package")
+
+// RedirectStdLog sends everything logged through the log package's default logger to
+// logger at level, and returns a func that restores the previous output.
+//
+// It fails with ErrStdLogLoop if logger ends in slog's own default handler, which writes
+// to the log package, either directly or through the handlers of this package. Other
+// wrappers cannot be seen through, so do not wrap slog.Default().Handler() in them.
+func RedirectStdLog(logger *slog.Logger, level slog.Level) (restore func(), err error) {
+	if writesToStdLog(logger.Handler()) {
+		return nil, ErrStdLogLoop
+	}
+	std := log.Default()
+	out, flags, prefix := std.Writer(), std.Flags(), std.Prefix()
+
+	std.SetOutput(&stdLogWriter{logger: logger, level: level})
+	std.SetFlags(0)
+	std.SetPrefix("")
+	return func() {
+		std.SetOutput(out)
+		std.SetFlags(flags)
+		std.SetPrefix(prefix)
+	}, nil
+}
+
+// writesToStdLog reports whether h, seen through the handlers of this package, ends in
+// slog's default handler.
+func writesToStdLog(h slog.Handler) bool {
+	switch h := h.(type) {
+	case *XlogHandler:
+		return writesToStdLog(h.handler)
+	case *DedupHandler:
+		return writesToStdLog(h.base)
+	case *SamplingHandler:
+		return writesToStdLog(h.handler)
+	case *MultiHandler:
+		return slices.ContainsFunc(h.handlers, writesToStdLog)
+	}
+	t := reflect.TypeOf(h)
+	return t != nil && t.Kind() == reflect.Pointer &&
+		t.Elem().PkgPath() == "log/slog" && t.Elem().Name() == "defaultHandler"
+}
+
+// SetServerErrorLog routes srv's error log (TLS handshake errors, panics in handlers,
+// accept errors, ...) to logger. Unrecognized messages are logged at error level.
+func SetServerErrorLog(srv *http.Server, logger *slog.Logger) {
+	srv.ErrorLog = NewStdLogger(logger, slog.LevelError)
+}
+
+// stdLogWriter receives one Write per message from a log.Logger.
+type stdLogWriter struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// stdLogPattern recognizes a net/http message. attrs names the submatches.
+type stdLogPattern struct {
+	re    *regexp.Regexp
+	level slog.Level
+	msg   string
+	attrs []string
+}
+
+var stdLogPatterns = []stdLogPattern{
+	{
+		re:    regexp.MustCompile(`^http: TLS handshake error from (\S+): (.*)$`),
+		level: slog.LevelWarn,
+		msg:   "TLS handshake error",
+		attrs: []string{"remote_addr", "error"},
+	},
+	{
+		re:    regexp.MustCompile(`(?s)^http: panic serving (\S+): (.*?)\n(.*)$`),
+		level: slog.LevelError,
+		msg:   "PANIC",
+		attrs: []string{"remote_addr", "panic", "stack"},
+	},
+	{
+		re:    regexp.MustCompile(`^http: Accept error: (.*); retrying in (\S+)$`),
+		level: slog.LevelError,
+		msg:   "accept error",
+		attrs: []string{"error", "retry_in"},
+	},
+	{
+		re:    regexp.MustCompile(`^http: superfluous response\.WriteHeader call from (\S+) \((.+)\)$`),
+		level: slog.LevelWarn,
+		msg:   "superfluous WriteHeader call",
+		attrs: []string{"caller", "caller_source"},
+	},
+	{
+		re:    regexp.MustCompile(`^http: URL query contains semicolon`),
+		level: slog.LevelWarn,
+		msg:   "URL query contains semicolon",
+	},
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	level, msg, attrs := parseStdLog(line, w.level)
+
+	ctx := context.Background()
+	if !w.logger.Enabled(ctx, level) {
+		return len(p), nil
+	}
+	r := slog.NewRecord(time.Now(), level, msg, stdLogCaller())
+	r.AddAttrs(attrs...)
+	_ = w.logger.Handler().Handle(ctx, r)
+	return len(p), nil
+}
+
+// parseStdLog matches line against the known net/http messages, falling back to the
+// line itself as the message.
+func parseStdLog(line string, level slog.Level) (slog.Level, string, []slog.Attr) {
+	for _, p := range stdLogPatterns {
+		m := p.re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		attrs := make([]slog.Attr, 0, len(p.attrs))
+		for i, key := range p.attrs {
+			if frames := parseStack(m[i+1]); key == "stack" && len(frames) > 0 {
+				attrs = append(attrs, slog.Any(key, frames))
+				continue
+			}
+			attrs = append(attrs, slog.String(key, m[i+1]))
+		}
+		return p.level, p.msg, attrs
+	}
+	return level, line, nil
+}
+
+// parseStack turns a goroutine stack as printed by runtime/debug.Stack into frames like
+// those of stackAttr, dropping the frames above the panic as stackFrames does.
+func parseStack(stack string) []Frame {
+	lines := strings.Split(stack, "\n")
+	var out []Frame
+	for i := 0; i+1 < len(lines); i++ {
+		fn, loc, ok := lines[i], lines[i+1], strings.HasPrefix(lines[i+1], "\t")
+		if !ok || strings.HasPrefix(fn, "\t") || strings.HasPrefix(fn, "created by ") {
+			continue
+		}
+		i++
+		if j := strings.LastIndexByte(fn, '('); j > 0 {
+			fn = fn[:j] // the arguments
+		}
+		if fn == "panic" {
+			out = out[:0]
+			continue
+		}
+		loc = strings.TrimPrefix(loc, "\t")
+		if j := strings.LastIndex(loc, " +0x"); j >= 0 {
+			loc = loc[:j]
+		}
+		file, line := loc, 0
+		if j := strings.LastIndexByte(loc, ':'); j >= 0 {
+			file = loc[:j]
+			line, _ = strconv.Atoi(loc[j+1:])
+		}
+		out = append(out, Frame{Function: fn, File: file, Line: line})
+	}
+	return out
+}
+
+// stdLogCaller returns the pc of the code that called into the log package.
+func stdLogCaller() uintptr {
+	var pcs [16]uintptr
+	n := runtime.Callers(3, pcs[:]) // skip runtime.Callers, stdLogCaller and Write
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "log.") {
+			return f.PC
+		}
+		if !more {
+			return 0
+		}
+	}
+}
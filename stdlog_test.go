@@ -0,0 +1,151 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_parseStdLog(t *testing.T) {
+	for _, tt := range []struct {
+		line  string
+		level slog.Level
+		msg   string
+		attrs map[string]string
+	}{
+		{
+			"http: TLS handshake error from 192.0.2.1:5555: EOF",
+			slog.LevelWarn, "TLS handshake error",
+			map[string]string{"remote_addr": "192.0.2.1:5555", "error": "EOF"},
+		},
+		{
+			"http: panic serving 192.0.2.1:5555: boom\ngoroutine 7 [running]:\nmain.handler()",
+			slog.LevelError, "PANIC",
+			map[string]string{"remote_addr": "192.0.2.1:5555", "panic": "boom", "stack": "goroutine 7 [running]:\nmain.handler()"},
+		},
+		{
+			"http: panic serving 192.0.2.1:5555: boom\ngoroutine 7 [running]:\n" +
+				"net/http.(*conn).serve.func1()\n\t/go/src/net/http/server.go:1947 +0xbe\n" +
+				"panic({0x6b2e40?, 0x7c3b10?})\n\t/go/src/runtime/panic.go:785 +0x132\n" +
+				"main.(*api).handler(0xc0000a0000, {0x7c9e58, 0xc0001c2000})\n\t/app/main.go:12 +0x25\n" +
+				"created by net/http.(*Server).Serve in goroutine 1\n\t/go/src/net/http/server.go:3360 +0x485",
+			slog.LevelError, "PANIC",
+			map[string]string{"remote_addr": "192.0.2.1:5555", "panic": "boom", "stack": "[{main.(*api).handler /app/main.go 12}]"},
+		},
+		{
+			"http: Accept error: accept tcp [::]:80: too many open files; retrying in 5ms",
+			slog.LevelError, "accept error",
+			map[string]string{"error": "accept tcp [::]:80: too many open files", "retry_in": "5ms"},
+		},
+		{
+			"http: superfluous response.WriteHeader call from main.handler (main.go:12)",
+			slog.LevelWarn, "superfluous WriteHeader call",
+			map[string]string{"caller": "main.handler", "caller_source": "main.go:12"},
+		},
+		{
+			"some library message",
+			slog.LevelInfo, "some library message",
+			map[string]string{},
+		},
+	} {
+		level, msg, attrs := parseStdLog(tt.line, slog.LevelInfo)
+		got := map[string]string{}
+		for _, a := range attrs {
+			got[a.Key] = a.Value.String()
+		}
+		if level != tt.level || msg != tt.msg || len(got) != len(tt.attrs) {
+			t.Errorf("parseStdLog(%q) = %v %q %v", tt.line, level, msg, got)
+			continue
+		}
+		for k, v := range tt.attrs {
+			if got[k] != v {
+				t.Errorf("parseStdLog(%q): %s = %q, want %q", tt.line, k, got[k], v)
+			}
+		}
+	}
+}
+
+func Test_SetServerErrorLog_Panic(t *testing.T) {
+	var buf bytes.Buffer
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	SetServerErrorLog(srv.Config, slog.New(slog.NewJSONHandler(&buf, nil)))
+	srv.Start()
+
+	resp, err := http.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	srv.Close() // waits for the handler goroutine, and so for the log line
+
+	var logged struct {
+		Level string  `json:"level"`
+		Msg   string  `json:"msg"`
+		Panic string  `json:"panic"`
+		Stack []Frame `json:"stack"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &logged); err != nil {
+		t.Fatalf("unmarshal log: %v\n%s", err, buf.String())
+	}
+	if logged.Level != "ERROR" || logged.Msg != "PANIC" || logged.Panic != "boom" || len(logged.Stack) == 0 {
+		t.Fatalf("unexpected record: %+v", logged)
+	}
+	if top := logged.Stack[0]; !strings.Contains(top.Function, "Test_SetServerErrorLog_Panic") ||
+		!strings.HasSuffix(top.File, "stdlog_test.go") || top.Line == 0 {
+		t.Errorf("expected the stack to start at the panicking handler, got %+v", top)
+	}
+}
+
+func Test_RedirectStdLog(t *testing.T) {
+	var buf bytes.Buffer
+	restore, err := RedirectStdLog(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true})), slog.LevelWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Printf("legacy %d", 1)
+	restore()
+
+	var logged struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Source struct {
+			File string `json:"file"`
+		} `json:"source"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &logged); err != nil {
+		t.Fatalf("unmarshal log: %v\n%s", err, buf.String())
+	}
+	if logged.Level != "WARN" || logged.Msg != "legacy 1" || !strings.HasSuffix(logged.Source.File, "stdlog_test.go") {
+		t.Errorf("unexpected record: %+v", logged)
+	}
+	if _, ok := log.Writer().(*stdLogWriter); ok {
+		t.Error("expected restore to put the previous output back")
+	}
+}
+
+// stdDefaultHandler is slog's own default handler, taken before any test replaces it.
+var stdDefaultHandler = slog.Default().Handler()
+
+func Test_RedirectStdLog_RejectsLoop(t *testing.T) {
+	for _, h := range []slog.Handler{
+		stdDefaultHandler,
+		NewHandler(stdDefaultHandler),
+		NewSamplingHandler(NewDedupHandler(stdDefaultHandler, nil)),
+		NewMultiHandler(slog.NewJSONHandler(io.Discard, nil), stdDefaultHandler),
+	} {
+		if _, err := RedirectStdLog(slog.New(h), slog.LevelInfo); !errors.Is(err, ErrStdLogLoop) {
+			t.Errorf("%T: expected ErrStdLogLoop, got %v", h, err)
+		}
+	}
+	if _, ok := log.Writer().(*stdLogWriter); ok {
+		t.Error("expected the log output to be left alone")
+	}
+}
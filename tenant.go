@@ -1,10 +1,23 @@
 package xlog
 
-import "github.com/labstack/echo/v4"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
 
 // Gets the tenant from the context and returns, or attempts to find and set.
 // Checks query params for tenantId, header for tenant, param for tenant in that order.
 // The first valid found value is returned.
+// For validation and other sources see TenantConfig and MiddlewareTenant.
 func GetTenant(c echo.Context) string {
 	if tenant, ok := c.Get("tenant").(string); ok {
 		return tenant
@@ -27,3 +40,272 @@ func SetTenant(c echo.Context, tenant string) string {
 	c.Set("tenant", tenant)
 	return tenant
 }
+
+// ---------------------------------------------------------------------
+// Configurable tenant resolution
+// ---------------------------------------------------------------------
+
+var (
+	ErrTenantMissing    = errors.New("xlog: tenant missing")
+	ErrTenantConflict   = errors.New("xlog: conflicting tenants")
+	ErrTenantNotAllowed = errors.New("xlog: tenant not allowed")
+)
+
+// TenantResolver finds the tenant of a request, reporting false if it has none.
+type TenantResolver interface {
+	ResolveTenant(r *http.Request) (string, bool)
+}
+
+// TenantResolverFunc adapts a function to a TenantResolver.
+type TenantResolverFunc func(r *http.Request) (string, bool)
+
+func (f TenantResolverFunc) ResolveTenant(r *http.Request) (string, bool) {
+	return f(r)
+}
+
+// namedResolver is a built-in resolver, named for conflict logs.
+type namedResolver struct {
+	name    string
+	resolve func(r *http.Request) (string, bool)
+}
+
+func (n namedResolver) ResolveTenant(r *http.Request) (string, bool) {
+	return n.resolve(r)
+}
+
+func (n namedResolver) String() string {
+	return n.name
+}
+
+// TenantFromQuery reads the tenant from query parameter name.
+func TenantFromQuery(name string) TenantResolver {
+	return namedResolver{"query:" + name, func(r *http.Request) (string, bool) {
+		v := r.URL.Query().Get(name)
+		return v, v != ""
+	}}
+}
+
+// TenantFromHeader reads the tenant from request header name.
+func TenantFromHeader(name string) TenantResolver {
+	return namedResolver{"header:" + name, func(r *http.Request) (string, bool) {
+		v := r.Header.Get(name)
+		return v, v != ""
+	}}
+}
+
+// TenantFromPathParam reads the tenant from path parameter name (r.PathValue).
+// MiddlewareTenant makes echo route params available this way.
+func TenantFromPathParam(name string) TenantResolver {
+	return namedResolver{"param:" + name, func(r *http.Request) (string, bool) {
+		v := r.PathValue(name)
+		return v, v != ""
+	}}
+}
+
+// TenantFromSubdomain reads the tenant from the label in front of baseDomain,
+// e.g. acme for acme.example.com with baseDomain example.com.
+func TenantFromSubdomain(baseDomain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+	return namedResolver{"subdomain:" + baseDomain, func(r *http.Request) (string, bool) {
+		host := strings.ToLower(r.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		label, ok := strings.CutSuffix(host, suffix)
+		if !ok || label == "" || strings.Contains(label, ".") {
+			return "", false
+		}
+		return label, true
+	}}
+}
+
+// TenantFromJWTClaim reads the tenant from a claim of the bearer token.
+// The token is not verified, so only use this behind authentication middleware.
+func TenantFromJWTClaim(claim string) TenantResolver {
+	return namedResolver{"jwt:" + claim, func(r *http.Request) (string, bool) {
+		claims, ok := bearerClaims(r)
+		if !ok {
+			return "", false
+		}
+		return stringClaim(claims, claim)
+	}}
+}
+
+// TenantStatic always resolves to tenant; use it last as a default.
+func TenantStatic(tenant string) TenantResolver {
+	return namedResolver{"static", func(*http.Request) (string, bool) {
+		return tenant, true
+	}}
+}
+
+// TenantConflictPolicy decides what happens when resolvers disagree.
+type TenantConflictPolicy int
+
+const (
+	// TenantConflictFirst uses the first resolver that finds a tenant and skips the rest.
+	TenantConflictFirst TenantConflictPolicy = iota
+	// TenantConflictLog uses the first tenant and logs a warning on disagreement.
+	TenantConflictLog
+	// TenantConflictReject fails the request on disagreement.
+	TenantConflictReject
+)
+
+// TenantConfig configures a tenant resolution chain.
+type TenantConfig struct {
+	// Skipper skips MiddlewareTenant for matching requests.
+	Skipper middleware.Skipper
+	// HTTPSkipper skips HTTPTenant for matching requests.
+	HTTPSkipper HTTPSkipper
+
+	// Resolvers are tried in order. Defaults to DefaultTenantResolvers.
+	Resolvers []TenantResolver
+
+	// CaseSensitive keeps tenants as given; by default they are lower-cased.
+	CaseSensitive bool
+	// Allowed restricts tenants to this list, if not empty. Entries are normalized like
+	// resolved tenants when the middleware is built.
+	Allowed []string
+	// Validate rejects tenants for which it returns false, if set.
+	Validate func(tenant string) bool
+
+	OnConflict TenantConflictPolicy
+
+	// Optional lets requests without a tenant through with an empty tenant.
+	Optional bool
+}
+
+// DefaultTenantResolvers mirrors GetTenant: query tenantId, header tenant, path param tenant.
+var DefaultTenantResolvers = []TenantResolver{
+	TenantFromQuery("tenantId"),
+	TenantFromHeader("tenant"),
+	TenantFromPathParam("tenant"),
+}
+
+// Resolve runs the chain for r. Errors wrap ErrTenantMissing, ErrTenantConflict or ErrTenantNotAllowed.
+// Allowed is compared as given; MiddlewareTenant and HTTPTenant normalize it once.
+func (config TenantConfig) Resolve(r *http.Request) (string, error) {
+	resolvers := config.Resolvers
+	if len(resolvers) == 0 {
+		resolvers = DefaultTenantResolvers
+	}
+
+	tenant, source := "", TenantResolver(nil)
+	for _, res := range resolvers {
+		v, ok := res.ResolveTenant(r)
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if !config.CaseSensitive {
+			v = strings.ToLower(v)
+		}
+		if v == "" {
+			continue
+		}
+		if source == nil {
+			tenant, source = v, res
+			if config.OnConflict == TenantConflictFirst {
+				break
+			}
+			continue
+		}
+		if v == tenant {
+			continue
+		}
+		if config.OnConflict == TenantConflictReject {
+			return "", fmt.Errorf("%w: %v=%q, %v=%q", ErrTenantConflict, source, tenant, res, v)
+		}
+		Warn(r.Context(), "tenant conflict",
+			slog.String("tenant", tenant), slog.Any("source", source),
+			slog.String("conflicting_tenant", v), slog.Any("conflicting_source", res),
+		)
+	}
+
+	if tenant == "" {
+		if config.Optional {
+			return "", nil
+		}
+		return "", ErrTenantMissing
+	}
+	if len(config.Allowed) > 0 && !slices.Contains(config.Allowed, tenant) {
+		return "", fmt.Errorf("%w: %q", ErrTenantNotAllowed, tenant)
+	}
+	if config.Validate != nil && !config.Validate(tenant) {
+		return "", fmt.Errorf("%w: %q", ErrTenantNotAllowed, tenant)
+	}
+	return tenant, nil
+}
+
+// MiddlewareTenant resolves and enforces the tenant: missing or conflicting tenants are
+// rejected with 400, disallowed ones with 403. The tenant is stored for GetTenant and in
+// the request info, so install it before the attach middlewares.
+func MiddlewareTenant(config TenantConfig) echo.MiddlewareFunc {
+	config = config.withDefaults()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			for i, name := range c.ParamNames() {
+				if i < len(c.ParamValues()) {
+					req.SetPathValue(name, c.ParamValues()[i])
+				}
+			}
+
+			ctx, tenant, err := config.apply(req)
+			if err != nil {
+				status, msg := tenantErrorStatus(err)
+				return echo.NewHTTPError(status, msg).SetInternal(err)
+			}
+
+			SetTenant(c, tenant)
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// withDefaults returns config with the defaults filled in and the Allowed entries
+// trimmed and, unless CaseSensitive, lower-cased, so that they compare equal to
+// resolved tenants.
+func (config TenantConfig) withDefaults() TenantConfig {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.HTTPSkipper == nil {
+		config.HTTPSkipper = defaultHTTPSkipper
+	}
+	if len(config.Allowed) > 0 {
+		allowed := make([]string, len(config.Allowed))
+		for i, a := range config.Allowed {
+			a = strings.TrimSpace(a)
+			if !config.CaseSensitive {
+				a = strings.ToLower(a)
+			}
+			allowed[i] = a
+		}
+		config.Allowed = allowed
+	}
+	return config
+}
+
+// apply resolves the tenant of r and returns r's context with the tenant stored in the request info.
+func (config TenantConfig) apply(r *http.Request) (context.Context, string, error) {
+	tenant, err := config.Resolve(r)
+	if err != nil {
+		return r.Context(), "", err
+	}
+	ctx := UpdateRequestInfo(r.Context(), func(info *RequestInfo) { info.Tenant = tenant })
+	return ctx, tenant, nil
+}
+
+// tenantErrorStatus maps a Resolve error to a response status and public message.
+func tenantErrorStatus(err error) (int, string) {
+	if errors.Is(err, ErrTenantNotAllowed) {
+		return http.StatusForbidden, "tenant not allowed"
+	}
+	return http.StatusBadRequest, "invalid tenant"
+}
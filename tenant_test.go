@@ -0,0 +1,72 @@
+package xlog
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func testJWT(payload string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"none"}`)) + "." + enc([]byte(payload)) + ".sig"
+}
+
+func Test_MiddlewareTenant_Chain(t *testing.T) {
+	config := TenantConfig{
+		Resolvers: []TenantResolver{
+			TenantFromSubdomain("example.com"),
+			TenantFromJWTClaim("tid"),
+			TenantFromQuery("tenantId"),
+			TenantFromPathParam("tenant"),
+		},
+		Allowed:    []string{" ACME", "globex"},
+		OnConflict: TenantConflictReject,
+	}
+
+	e := echo.New()
+	e.Use(MiddlewareTenant(config))
+	var got string
+	handler := func(c echo.Context) error {
+		got = Tenant(c.Request().Context())
+		if GetTenant(c) != got {
+			t.Errorf("GetTenant %q disagrees with request info %q", GetTenant(c), got)
+		}
+		return c.NoContent(http.StatusOK)
+	}
+	e.GET("/", handler)
+	e.GET("/t/:tenant", handler)
+
+	cases := []struct {
+		name   string
+		host   string
+		target string
+		jwt    string
+		status int
+		tenant string
+	}{
+		{"subdomain", "ACME.example.com:8080", "/", "", http.StatusOK, "acme"},
+		{"jwt claim", "api.other.org", "/", `{"tid":"globex"}`, http.StatusOK, "globex"},
+		{"path param", "localhost", "/t/Acme", "", http.StatusOK, "acme"},
+		{"agreeing sources", "acme.example.com", "/?tenantId=acme", "", http.StatusOK, "acme"},
+		{"conflict", "acme.example.com", "/?tenantId=globex", "", http.StatusBadRequest, ""},
+		{"not allowed", "localhost", "/?tenantId=initech", "", http.StatusForbidden, ""},
+		{"missing", "localhost", "/", "", http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		got = ""
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		req.Host = tc.host
+		if tc.jwt != "" {
+			req.Header.Set("Authorization", "Bearer "+testJWT(tc.jwt))
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != tc.status || got != tc.tenant {
+			t.Errorf("%s: want %d/%q, got %d/%q", tc.name, tc.status, tc.tenant, rec.Code, got)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package xlog
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TransportOptions configures Transport.
+type TransportOptions struct {
+	// Level is used for successful calls, ErrorLevel for transport errors and 5xx responses.
+	// Default to slog.LevelInfo and slog.LevelWarn.
+	Level      slog.Leveler
+	ErrorLevel slog.Leveler
+
+	// RequestIDHeader and TenantHeader carry the request id and tenant of the calling
+	// request to the next service. Default to X-Request-ID and tenant, matching what
+	// MiddlewareRequestID and DefaultTenantResolvers read.
+	RequestIDHeader string
+	TenantHeader    string
+	// NoPropagation disables the X-Request-ID, tenant and traceparent headers.
+	NoPropagation bool
+
+	// Redact decides which query params appear in the logged url, and which headers
+	// are logged with LogHeaders. Defaults to DefaultRedactPolicy.
+	Redact *RedactPolicy
+	// LogHeaders logs the allowed request and response headers as the http group,
+	// see RedactPolicy.
+	LogHeaders bool
+}
+
+// Transport returns a RoundTripper that logs every call made through base with the
+// logger from the request context, and forwards the request id and tenant of the
+// calling request along with a W3C traceparent, see traceparent. A nil base means
+// http.DefaultTransport.
+//
+//	client := &http.Client{Transport: xlog.Transport(nil, nil)}
+//	req, _ := http.NewRequestWithContext(xlog.WithRouteTemplate(ctx, "/users/{id}"), "GET", url, nil)
+//
+// Headers already set on the request are left alone.
+func Transport(base http.RoundTripper, opts *TransportOptions) http.RoundTripper {
+	t := &transport{base: base}
+	if opts != nil {
+		t.opts = *opts
+	}
+	if t.base == nil {
+		t.base = http.DefaultTransport
+	}
+	if t.opts.Level == nil {
+		t.opts.Level = slog.LevelInfo
+	}
+	if t.opts.ErrorLevel == nil {
+		t.opts.ErrorLevel = slog.LevelWarn
+	}
+	if t.opts.RequestIDHeader == "" {
+		t.opts.RequestIDHeader = "X-Request-ID"
+	}
+	if t.opts.TenantHeader == "" {
+		t.opts.TenantHeader = "tenant"
+	}
+	if t.opts.Redact == nil {
+		t.opts.Redact = &DefaultRedactPolicy
+	}
+	return t
+}
+
+type transport struct {
+	base http.RoundTripper
+	opts TransportOptions
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !t.opts.NoPropagation {
+		req = t.propagate(ctx, req)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	duration := time.Since(start)
+
+	attrs := make([]slog.Attr, 0, 9)
+	attrs = append(attrs,
+		slog.String("method", req.Method),
+		slog.String("host", req.URL.Host),
+	)
+	if route := routeTemplate(ctx); route != "" {
+		attrs = append(attrs, slog.String("route", route))
+	}
+	attrs = append(attrs, slog.String("url", t.redactURL(req.URL)))
+
+	level := t.opts.Level.Level()
+	msg := "HTTP_CLIENT"
+	if err != nil {
+		level, msg = t.opts.ErrorLevel.Level(), "HTTP_CLIENT_ERROR"
+	} else {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			level = t.opts.ErrorLevel.Level()
+		}
+	}
+	attrs = append(attrs,
+		slog.Int64("duration_ms", duration.Milliseconds()),
+		slog.Int("retry", retryCount(ctx)),
+	)
+	if t.opts.LogHeaders {
+		var respHeader http.Header
+		if resp != nil {
+			respHeader = resp.Header
+		}
+		attrs = append(attrs, t.opts.Redact.httpAttr(req.Header, "", respHeader))
+	}
+	if err != nil {
+		attrs = append(attrs, Err(err))
+	}
+
+	FromContext(ctx).LogAttrs(ctx, level, msg, attrs...)
+	return resp, err
+}
+
+// propagate returns a copy of req carrying the headers of the calling request.
+// A RoundTripper must not modify the request it is given.
+func (t *transport) propagate(ctx context.Context, req *http.Request) *http.Request {
+	reqID, tenant := RequestID(ctx), Tenant(ctx)
+	set := map[string]string{}
+	if reqID != "" {
+		set[t.opts.RequestIDHeader] = reqID
+	}
+	if tp := traceparent(ctx); tp != "" {
+		set["traceparent"] = tp
+	}
+	if tenant != "" {
+		set[t.opts.TenantHeader] = tenant
+	}
+
+	var out *http.Request
+	for k, v := range set {
+		if req.Header.Get(k) != "" {
+			continue
+		}
+		if out == nil {
+			out = req.Clone(ctx)
+		}
+		out.Header.Set(k, v)
+	}
+	if out == nil {
+		return req
+	}
+	return out
+}
+
+func (t *transport) redactURL(u *url.URL) string {
+	ru := *u
+	ru.User = nil
+	ru.RawQuery = t.opts.Redact.redactQuery(ru.RawQuery)
+	return ru.String()
+}
+
+// traceparent builds the W3C traceparent of an outbound call of the request in ctx,
+// with a random parent id per call. The trace id and flags of the inbound traceparent
+// are kept if the request had one. Otherwise the trace id is derived from the request
+// id, ULIDs as is and other ids hashed, so every outbound call of one request shares
+// a trace, and the call is flagged as sampled. It returns "" without either.
+func traceparent(ctx context.Context) string {
+	var traceID, flags string
+	if info, _ := RequestInfoFromContext(ctx); info.TraceParent != "" {
+		traceID, flags = info.TraceParent[3:35], info.TraceParent[53:55]
+	} else if reqID := RequestID(ctx); reqID != "" {
+		b, ok := ulidBytes(reqID)
+		if !ok {
+			sum := sha256.Sum256([]byte(reqID))
+			copy(b[:], sum[:16])
+		}
+		traceID, flags = hex.EncodeToString(b[:]), "01"
+	} else {
+		return ""
+	}
+	var parentID [8]byte
+	_, _ = rand.Read(parentID[:])
+	return "00-" + traceID + "-" + hex.EncodeToString(parentID[:]) + "-" + flags
+}
+
+// validTraceparent reports whether tp is a version 00 traceparent with a non-zero
+// trace and parent id. Future versions are not continued.
+func validTraceparent(tp string) bool {
+	if len(tp) != 55 || tp[:3] != "00-" || tp[35] != '-' || tp[52] != '-' {
+		return false
+	}
+	traceID, parentID, flags := tp[3:35], tp[36:52], tp[53:55]
+	return lowerHex(traceID) && lowerHex(parentID) && lowerHex(flags) &&
+		strings.Trim(traceID, "0") != "" && strings.Trim(parentID, "0") != ""
+}
+
+func lowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ulidBytes decodes a ULID as produced by NewRequestID.
+func ulidBytes(id string) ([16]byte, bool) {
+	var b [16]byte
+	if len(id) != 26 || id[0] > '7' {
+		return b, false
+	}
+	var hi, lo uint64
+	for i := 0; i < len(id); i++ {
+		v := strings.IndexByte(ulidAlphabet, id[i])
+		if v < 0 {
+			return b, false
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	for i := 0; i < 8; i++ {
+		b[i] = byte(hi >> (56 - 8*i))
+		b[8+i] = byte(lo >> (56 - 8*i))
+	}
+	return b, true
+}
+
+// Keys for the outbound call annotations.
+type (
+	ctxRouteTemplateKey struct{}
+	ctxRetryKey         struct{}
+)
+
+// WithRouteTemplate returns ctx in which Transport logs route as the route of the call,
+// e.g. /users/{id}. Unlike the url it has low cardinality.
+func WithRouteTemplate(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, ctxRouteTemplateKey{}, route)
+}
+
+// WithRetry returns ctx in which Transport logs retry as the retry count of the call.
+// Retry loops set it per attempt, starting at 0.
+func WithRetry(ctx context.Context, retry int) context.Context {
+	return context.WithValue(ctx, ctxRetryKey{}, retry)
+}
+
+func routeTemplate(ctx context.Context) string {
+	route, _ := ctx.Value(ctxRouteTemplateKey{}).(string)
+	return route
+}
+
+func retryCount(ctx context.Context) int {
+	retry, _ := ctx.Value(ctxRetryKey{}).(int)
+	return retry
+}
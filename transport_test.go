@@ -0,0 +1,120 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Transport_PropagatesAndLogs(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	reqID := NewRequestID()
+	ctx := RequestInfoToContext(t.Context(), RequestInfo{Tenant: "acme", RequestID: reqID})
+	ctx = ToContext(ctx, slog.New(slog.NewJSONHandler(&buf, nil)))
+	ctx = WithRetry(WithRouteTemplate(ctx, "/users/{id}"), 2)
+
+	client := &http.Client{Transport: Transport(nil, &TransportOptions{LogHeaders: true})}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/users/7?token=s3cret&page=2", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got.Get("X-Request-ID") != reqID || got.Get("tenant") != "acme" {
+		t.Errorf("expected request id and tenant to be forwarded, got %v", got)
+	}
+	tp := strings.Split(got.Get("traceparent"), "-")
+	want, _ := ulidBytes(reqID)
+	if len(tp) != 4 || tp[1] != hex.EncodeToString(want[:]) {
+		t.Errorf("expected the trace id to be the request id bytes, got %q", got.Get("traceparent"))
+	}
+	if req.Header.Get("X-Request-ID") != "" {
+		t.Error("expected the caller's request to be left alone")
+	}
+
+	if strings.Contains(buf.String(), "s3cret") {
+		t.Errorf("expected secrets to be redacted, got %s", buf.String())
+	}
+	var logged struct {
+		Msg    string `json:"msg"`
+		Method string `json:"method"`
+		Route  string `json:"route"`
+		Status int    `json:"status"`
+		Retry  int    `json:"retry"`
+		Tenant string `json:"tenant"`
+		HTTP   struct {
+			Request struct {
+				Header map[string]string `json:"header"`
+			} `json:"request"`
+		} `json:"http"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &logged); err != nil {
+		t.Fatalf("unmarshal log: %v\n%s", err, buf.String())
+	}
+	if logged.Msg != "HTTP_CLIENT" || logged.Method != "GET" || logged.Route != "/users/{id}" ||
+		logged.Status != http.StatusTeapot || logged.Retry != 2 || logged.HTTP.Request.Header["authorization"] != redacted {
+		t.Errorf("unexpected log record: %+v", logged)
+	}
+}
+
+func Test_traceparent_ContinuesInbound(t *testing.T) {
+	const inbound = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("traceparent", inbound)
+	ctx := RequestInfoToContext(t.Context(), newRequestInfo(t.Context(), r, "", "req-1", "", ""))
+
+	tp := strings.Split(traceparent(ctx), "-")
+	if len(tp) != 4 || tp[1] != "4bf92f3577b34da6a3ce929d0e0e4736" || tp[3] != "00" {
+		t.Errorf("expected the inbound trace id and flags, got %v", tp)
+	}
+	if tp[2] == "00f067aa0ba902b7" {
+		t.Error("expected a new parent id for the outbound call")
+	}
+
+	for _, bad := range []string{"01-" + inbound[3:], "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "garbage"} {
+		r.Header.Set("traceparent", bad)
+		if info := newRequestInfo(t.Context(), r, "", "req-1", "", ""); info.TraceParent != "" {
+			t.Errorf("expected %q to be ignored, got %q", bad, info.TraceParent)
+		}
+	}
+}
+
+func Test_ulidBytes_RoundTrip(t *testing.T) {
+	id := NewRequestID()
+	b, ok := ulidBytes(id)
+	if !ok {
+		t.Fatalf("expected %q to decode", id)
+	}
+	// Re-encode with the same loop NewRequestID uses.
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(b[i])
+		lo = lo<<8 | uint64(b[8+i])
+	}
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = ulidAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	if string(out[:]) != id {
+		t.Errorf("round trip gave %q, want %q", out[:], id)
+	}
+	if _, ok := ulidBytes("not-a-ulid"); ok {
+		t.Error("expected other ids to be rejected")
+	}
+}
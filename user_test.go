@@ -0,0 +1,51 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_MiddlewareUser_BeforeAndAfterAttach(t *testing.T) {
+	key := []byte("secret")
+	for _, userFirst := range []bool{true, false} {
+		var buf bytes.Buffer
+		logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil), DefaultPerRequestArgs))
+		user := MiddlewareUser(UserConfig{PseudonymKey: key})
+
+		e := echo.New()
+		if userFirst {
+			e.Use(user, MiddlewareAttachDefaultsLogger(logger))
+		} else {
+			e.Use(MiddlewareAttachDefaultsLogger(logger), user)
+		}
+		e.GET("/", func(c echo.Context) error {
+			InfoC(c, "hello")
+			return c.NoContent(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+testJWT(`{"sub":"alice@example.com"}`))
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		var rec map[string]any
+		if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &rec); err != nil {
+			t.Fatalf("unmarshal: %v\n%s", err, buf.String())
+		}
+		if want := Pseudonymize(key, "alice@example.com"); rec["user"] != want {
+			t.Errorf("userFirst=%v: want user=%s, got %v", userFirst, want, rec)
+		}
+		// Checked on the raw line: a duplicate key would be hidden by json.Unmarshal.
+		if n := bytes.Count(buf.Bytes(), []byte(`"user"`)); n != 1 {
+			t.Errorf("userFirst=%v: expected the user once, got %s", userFirst, buf.String())
+		}
+		if bytes.Contains(buf.Bytes(), []byte("alice")) {
+			t.Errorf("userFirst=%v: raw user id leaked: %s", userFirst, buf.String())
+		}
+	}
+}
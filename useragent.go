@@ -0,0 +1,213 @@
+package xlog
+
+import (
+	"container/list"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// UserAgent is the structured form of a User-Agent header.
+type UserAgent struct {
+	Browser        string
+	BrowserVersion string
+	OS             string
+	// Device is desktop, mobile, tablet, bot or other.
+	Device string
+	Bot    bool
+}
+
+// attr returns ua as the "user_agent" group, with the raw string if keepRaw is set.
+func (ua UserAgent) attr(raw string, keepRaw bool) slog.Attr {
+	attrs := make([]any, 0, 6)
+	attrs = append(attrs,
+		slog.String("browser", ua.Browser),
+		slog.String("browser_version", ua.BrowserVersion),
+		slog.String("os", ua.OS),
+		slog.String("device", ua.Device),
+		slog.Bool("bot", ua.Bot),
+	)
+	if keepRaw {
+		attrs = append(attrs, slog.String("raw", raw))
+	}
+	return slog.Group("user_agent", attrs...)
+}
+
+// UserAgentParser parses User-Agent headers, caching the most recent results since a
+// service tends to see the same few strings over and over.
+type UserAgentParser struct {
+	size int
+
+	mu    sync.Mutex
+	order *list.List // of *uaEntry, most recent first
+	cache map[string]*list.Element
+}
+
+type uaEntry struct {
+	raw string
+	ua  UserAgent
+}
+
+// NewUserAgentParser returns a parser caching up to size results. Defaults to 1024.
+func NewUserAgentParser(size int) *UserAgentParser {
+	if size <= 0 {
+		size = 1024
+	}
+	return &UserAgentParser{size: size, order: list.New(), cache: make(map[string]*list.Element, size)}
+}
+
+// DefaultUserAgentParser is used by the request loggers.
+var DefaultUserAgentParser = NewUserAgentParser(1024)
+
+// maxCachedUserAgent is the longest User-Agent that is cached. Real ones are shorter,
+// and longer ones could otherwise pin a lot of memory in the cache keys.
+const maxCachedUserAgent = 512
+
+// Parse returns the structured form of raw.
+func (p *UserAgentParser) Parse(raw string) UserAgent {
+	if len(raw) > maxCachedUserAgent {
+		return ParseUserAgent(raw)
+	}
+	p.mu.Lock()
+	if el, ok := p.cache[raw]; ok {
+		p.order.MoveToFront(el)
+		ua := el.Value.(*uaEntry).ua
+		p.mu.Unlock()
+		return ua
+	}
+	p.mu.Unlock()
+
+	ua := ParseUserAgent(raw)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.cache[raw]; !ok {
+		p.cache[raw] = p.order.PushFront(&uaEntry{raw, ua})
+		if p.order.Len() > p.size {
+			oldest := p.order.Back()
+			p.order.Remove(oldest)
+			delete(p.cache, oldest.Value.(*uaEntry).raw)
+		}
+	}
+	return ua
+}
+
+// uaBrowsers are checked in order; browsers that copy other browsers' tokens (Edge
+// and Opera claim to be Chrome, Chrome claims to be Safari) come first.
+var uaBrowsers = []struct {
+	token, family string
+}{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"MSIE ", "Internet Explorer"},
+	{"curl/", "curl"},
+	{"Wget/", "Wget"},
+	{"python-requests/", "python-requests"},
+	{"Go-http-client/", "Go-http-client"},
+}
+
+// uaBotMarkers flag crawlers and scripted clients, compared lower-cased.
+var uaBotMarkers = []string{
+	"bot", "crawl", "spider", "slurp", "facebookexternalhit", "headless",
+	"curl/", "wget/", "python-", "go-http-client", "java/", "okhttp",
+}
+
+// ParseUserAgent extracts the browser, OS and device class from raw with a handful of
+// well known tokens. It does not aim for the precision of a full UA database.
+func ParseUserAgent(raw string) UserAgent {
+	ua := UserAgent{Browser: "Other", OS: "Other", Device: "other"}
+	lower := strings.ToLower(raw)
+	for _, m := range uaBotMarkers {
+		if strings.Contains(lower, m) {
+			ua.Bot = true
+			break
+		}
+	}
+
+	ua.Browser, ua.BrowserVersion = uaBrowser(raw)
+	if ua.Bot {
+		// Crawlers often claim a browser too; their own name is more useful.
+		if name, version, ok := uaBotName(raw); ok {
+			ua.Browser, ua.BrowserVersion = name, version
+		}
+	}
+
+	switch {
+	case strings.Contains(raw, "Windows"):
+		ua.OS = "Windows"
+	case strings.Contains(raw, "iPhone"), strings.Contains(raw, "iPad"), strings.Contains(raw, "iPod"):
+		ua.OS = "iOS"
+	case strings.Contains(raw, "Mac OS X"), strings.Contains(raw, "Macintosh"):
+		ua.OS = "macOS"
+	case strings.Contains(raw, "Android"):
+		ua.OS = "Android"
+	case strings.Contains(raw, "CrOS"):
+		ua.OS = "ChromeOS"
+	case strings.Contains(raw, "Linux"):
+		ua.OS = "Linux"
+	}
+
+	switch {
+	case ua.Bot:
+		ua.Device = "bot"
+	case strings.Contains(raw, "iPad"), strings.Contains(raw, "Tablet"),
+		ua.OS == "Android" && !strings.Contains(raw, "Mobile"):
+		ua.Device = "tablet"
+	case strings.Contains(raw, "Mobi"), strings.Contains(raw, "iPhone"), strings.Contains(raw, "iPod"):
+		ua.Device = "mobile"
+	case ua.OS == "Windows", ua.OS == "macOS", ua.OS == "Linux", ua.OS == "ChromeOS":
+		ua.Device = "desktop"
+	}
+	return ua
+}
+
+func uaBrowser(raw string) (family, version string) {
+	for _, b := range uaBrowsers {
+		if i := strings.Index(raw, b.token); i >= 0 {
+			return b.family, uaVersion(raw[i+len(b.token):])
+		}
+	}
+	if strings.Contains(raw, "Trident/") {
+		if i := strings.Index(raw, "rv:"); i >= 0 {
+			return "Internet Explorer", uaVersion(raw[i+3:])
+		}
+		return "Internet Explorer", ""
+	}
+	if strings.Contains(raw, "Safari/") {
+		if i := strings.Index(raw, "Version/"); i >= 0 {
+			return "Safari", uaVersion(raw[i+len("Version/"):])
+		}
+		return "Safari", ""
+	}
+	return "Other", ""
+}
+
+// uaVersion returns the leading dotted version number of s.
+func uaVersion(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if end < 0 {
+		end = len(s)
+	}
+	return strings.TrimRight(s[:end], ".")
+}
+
+// uaBotName returns the product token naming a crawler, e.g. Googlebot/2.1 in
+// "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)".
+func uaBotName(raw string) (name, version string, ok bool) {
+	for _, f := range strings.FieldsFunc(raw, func(r rune) bool { return r == ' ' || r == ';' || r == '(' || r == ')' }) {
+		name, version, _ := strings.Cut(f, "/")
+		lower := strings.ToLower(name)
+		if !strings.Contains(lower, ".") && !strings.HasPrefix(lower, "+") &&
+			(strings.Contains(lower, "bot") || strings.Contains(lower, "crawl") || strings.Contains(lower, "spider")) {
+			return name, uaVersion(version), true
+		}
+	}
+	return "", "", false
+}
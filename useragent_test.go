@@ -0,0 +1,105 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func Test_ParseUserAgent(t *testing.T) {
+	for _, tt := range []struct {
+		raw  string
+		want UserAgent
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.91 Safari/537.36",
+			UserAgent{"Chrome", "124.0.6367.91", "Windows", "desktop", false},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.67",
+			UserAgent{"Edge", "124.0.2478.67", "Windows", "desktop", false},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			UserAgent{"Safari", "17.4", "iOS", "mobile", false},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			UserAgent{"Chrome", "124.0.0.0", "Android", "tablet", false},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 14.4; rv:125.0) Gecko/20100101 Firefox/125.0",
+			UserAgent{"Firefox", "125.0", "macOS", "desktop", false},
+		},
+		{
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			UserAgent{"Googlebot", "2.1", "Other", "bot", true},
+		},
+		{
+			"curl/8.5.0",
+			UserAgent{"curl", "8.5.0", "Other", "bot", true},
+		},
+		{
+			"",
+			UserAgent{"Other", "", "Other", "other", false},
+		},
+	} {
+		if got := ParseUserAgent(tt.raw); got != tt.want {
+			t.Errorf("ParseUserAgent(%q)\n got %+v\nwant %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func Test_UserAgentParser_Evicts(t *testing.T) {
+	p := NewUserAgentParser(2)
+	p.Parse("a")
+	p.Parse("b")
+	p.Parse("a") // a is now the most recent
+	p.Parse("c")
+
+	if _, ok := p.cache["b"]; ok || len(p.cache) != 2 {
+		t.Errorf("expected b to be evicted, cache has %d entries", len(p.cache))
+	}
+
+	long := "curl/8.0 " + strings.Repeat("x", maxCachedUserAgent)
+	if ua := p.Parse(long); ua.Browser != "curl" {
+		t.Errorf("expected long user agents to be parsed, got %+v", ua)
+	}
+	if _, ok := p.cache[long]; ok {
+		t.Error("expected long user agents not to be cached")
+	}
+}
+
+func Test_RequestLogger_ParsesUserAgent(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.Use(MiddlewareAttachDefaultsLogger(logger))
+	e.Use(MiddlewareRequestLoggerWithConfig(RequestLoggerConfig{LogUserAgent: true, ParseUserAgent: true, KeepRawUserAgent: true}))
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	var logged struct {
+		UserAgent struct {
+			Browser string `json:"browser"`
+			Bot     bool   `json:"bot"`
+			Raw     string `json:"raw"`
+		} `json:"user_agent"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &logged); err != nil {
+		t.Fatalf("unmarshal log: %v\n%s", err, buf.String())
+	}
+	if logged.UserAgent.Browser != "curl" || !logged.UserAgent.Bot || logged.UserAgent.Raw != "curl/8.5.0" {
+		t.Errorf("unexpected user_agent group: %+v", logged.UserAgent)
+	}
+}
@@ -3,6 +3,9 @@ package xlog
 import (
 	"context"
 	"log/slog"
+	"runtime"
+	"sync/atomic"
+	"time"
 )
 
 // Key for With-managed deduped attrs stored separately from the logger.
@@ -25,36 +28,72 @@ func ToContext(ctx context.Context, logger *slog.Logger) context.Context {
 // Get the logger from the context with the logger key, or default logger.
 // Any attrs added via With are applied on top of the base logger.
 func FromContext(ctx context.Context) *slog.Logger {
-	l := slog.Default()
-	if stored, ok := ctx.Value(ctxLoggerKey{}).(*slog.Logger); ok && stored != nil {
-		l = stored
-	}
-	if attrs := withAttrsFromContext(ctx); len(attrs) > 0 {
-		args := make([]any, len(attrs))
-		for i, a := range attrs {
-			args[i] = a
+	l := baseLogger(ctx)
+	if st := withStateFromContext(ctx); st != nil {
+		if st.base == l {
+			return st.logger
 		}
-		l = l.With(args...)
+		// The base logger changed after With (ToContext or slog.SetDefault), so the
+		// cached logger is stale; derive one for the new base, once.
+		if d := st.rebased.Load(); d != nil && d.base == l {
+			return d.logger
+		}
+		d := &derivedLogger{base: l, logger: slog.New(l.Handler().WithAttrs(st.attrs))}
+		st.rebased.Store(d)
+		return d.logger
 	}
 	return l
 }
 
 // With adds or updates key–value pairs on the logger carried in the context.
 // If a key already exists from a previous With call, its value is replaced
-// so that duplicate keys are never emitted.
+// so that duplicate keys are never emitted. Keys declared with Protect are
+// renamed or dropped instead.
+//
+// The derived logger is built once here, so logging through FromContext afterwards
+// does not allocate.
 func With(ctx context.Context, args ...any) context.Context {
-	existing := withAttrsFromContext(ctx)
-	incoming := argsToAttrs(args)
-	merged := mergeAttrs(existing, incoming)
-	return context.WithValue(ctx, ctxWithAttrsKey{}, merged)
+	var existing []slog.Attr
+	if st := withStateFromContext(ctx); st != nil {
+		existing = st.attrs
+	}
+	merged := mergeAttrs(existing, protectAttrs(ctx, argsToAttrs(args)))
+	base := baseLogger(ctx)
+	return context.WithValue(ctx, ctxWithAttrsKey{}, &withState{
+		attrs:  merged,
+		base:   base,
+		logger: slog.New(base.Handler().WithAttrs(merged)),
+	})
+}
+
+// withState is what With stores in the context: the deduped attrs and the
+// logger derived from applying them to base.
+type withState struct {
+	attrs  []slog.Attr
+	base   *slog.Logger
+	logger *slog.Logger
+
+	// rebased is the logger derived for a base logger replaced after With.
+	rebased atomic.Pointer[derivedLogger]
 }
 
-// withAttrsFromContext returns the deduped attrs managed by With.
-func withAttrsFromContext(ctx context.Context) []slog.Attr {
-	if v, ok := ctx.Value(ctxWithAttrsKey{}).([]slog.Attr); ok {
-		return v
+type derivedLogger struct {
+	base   *slog.Logger
+	logger *slog.Logger
+}
+
+// baseLogger returns the logger stored with ToContext, or the default logger.
+func baseLogger(ctx context.Context) *slog.Logger {
+	if stored, ok := ctx.Value(ctxLoggerKey{}).(*slog.Logger); ok && stored != nil {
+		return stored
 	}
-	return nil
+	return slog.Default()
+}
+
+// withStateFromContext returns the state managed by With, if any.
+func withStateFromContext(ctx context.Context) *withState {
+	st, _ := ctx.Value(ctxWithAttrsKey{}).(*withState)
+	return st
 }
 
 // argsToAttrs converts slog-style args (key, value, key, value, …) into a
@@ -82,22 +121,42 @@ func argsToAttrs(args []any) []slog.Attr {
 	return attrs
 }
 
+// Above this many attrs mergeAttrs indexes keys with a map instead of scanning.
+const mergeMapThreshold = 8
+
 // mergeAttrs merges incoming attrs into existing, replacing by key.
 func mergeAttrs(existing, incoming []slog.Attr) []slog.Attr {
-	result := make([]slog.Attr, len(existing))
+	result := make([]slog.Attr, len(existing), len(existing)+len(incoming))
 	copy(result, existing)
-	for _, na := range incoming {
-		replaced := false
-		for i, ea := range result {
-			if ea.Key == na.Key {
-				result[i] = na
-				replaced = true
-				break
+
+	if len(existing)+len(incoming) <= mergeMapThreshold {
+		for _, na := range incoming {
+			replaced := false
+			for i, ea := range result {
+				if ea.Key == na.Key {
+					result[i] = na
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				result = append(result, na)
 			}
 		}
-		if !replaced {
-			result = append(result, na)
+		return result
+	}
+
+	index := make(map[string]int, len(existing)+len(incoming))
+	for i, ea := range result {
+		index[ea.Key] = i
+	}
+	for _, na := range incoming {
+		if i, ok := index[na.Key]; ok {
+			result[i] = na
+			continue
 		}
+		index[na.Key] = len(result)
+		result = append(result, na)
 	}
 	return result
 }
@@ -106,18 +165,40 @@ func mergeAttrs(existing, incoming []slog.Attr) []slog.Attr {
 // Public helpers (call these directly in handlers)
 // ---------------------------------------------------------------------
 
+// The helpers below build the record themselves so that, with AddSource enabled,
+// `source` points at their caller rather than at this file.
+
 func Debug(ctx context.Context, msg string, args ...any) {
-	FromContext(ctx).DebugContext(ctx, msg, args...)
+	logDepth(ctx, 1, slog.LevelDebug, msg, args...)
 }
 
 func Info(ctx context.Context, msg string, args ...any) {
-	FromContext(ctx).InfoContext(ctx, msg, args...)
+	logDepth(ctx, 1, slog.LevelInfo, msg, args...)
 }
 
 func Warn(ctx context.Context, msg string, args ...any) {
-	FromContext(ctx).WarnContext(ctx, msg, args...)
+	logDepth(ctx, 1, slog.LevelWarn, msg, args...)
 }
 
 func Error(ctx context.Context, msg string, err error, args ...any) {
-	FromContext(ctx).ErrorContext(ctx, msg, append(args, slog.String("error", err.Error()))...)
+	logDepth(ctx, 1, slog.LevelError, msg, append(args, Err(err))...)
+}
+
+// LogDepth logs through FromContext(ctx), attributing the record to the frame depth
+// levels above its caller. LogDepth(ctx, 0, ...) reports the line calling LogDepth;
+// a wrapper library passes 1 to report its own caller instead.
+func LogDepth(ctx context.Context, depth int, level slog.Level, msg string, args ...any) {
+	logDepth(ctx, depth+1, level, msg, args...)
+}
+
+func logDepth(ctx context.Context, depth int, level slog.Level, msg string, args ...any) {
+	l := FromContext(ctx)
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(depth+2, pcs[:]) // skip runtime.Callers and logDepth
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	_ = l.Handler().Handle(ctx, r)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"strconv"
 	"testing"
 )
 
@@ -64,7 +65,7 @@ func BenchmarkAttrsInContext_XlogHandler(b *testing.B) {
 	}
 }
 
-// Benchmark 3: store args slice in context, inject via XlogHandler on each call
+// Benchmark 3: store RequestInfo in context, inject its prebuilt attrs via XlogHandler on each call
 func BenchmarkAttrsSliceInContext_XlogHandler(b *testing.B) {
 	// Wrapped handler: XlogHandler adds attrs each Handle from ctx
 	inner := slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
@@ -80,16 +81,13 @@ func BenchmarkAttrsSliceInContext_XlogHandler(b *testing.B) {
 	// ctx = context.WithValue(ctx, CtxMethodKey, "GET")
 	// ctx = context.WithValue(ctx, CtxURIPathKey, "/api/orders")
 
-	// Create default attrs and store in context:
-	attrs := []slog.Attr{
-		slog.String(string(CtxTenantKey), "test-tenant"),
-		slog.String(string(CtxReqIDKey), "req-123"),
-		slog.String(string(CtxMethodKey), "GET"),
-		slog.String(string(CtxURIPathKey), "/api/orders"),
-	}
-
-	ctx := context.Background()
-	ctx = context.WithValue(ctx, ctxAttrsKey{}, attrs)
+	// Store the request info once, as MiddlewareAttachDefaultsCtx does:
+	ctx := RequestInfoToContext(context.Background(), RequestInfo{
+		Tenant:    "test-tenant",
+		RequestID: "req-123",
+		Method:    "GET",
+		Path:      "/api/orders",
+	})
 
 	b.ReportAllocs()
 
@@ -99,6 +97,64 @@ func BenchmarkAttrsSliceInContext_XlogHandler(b *testing.B) {
 	}
 }
 
+// Benchmark 4: request path that adds attrs with With, then logs several times
+func BenchmarkWithInContext(b *testing.B) {
+	base := slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})
+	ctx := ToContext(context.Background(), slog.New(base))
+	ctx = With(ctx, "tenant", "test-tenant", "request_id", "req-123")
+	ctx = With(ctx, "order_id", 42, "tenant", "other-tenant")
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		// With-managed attrs are already bound, so this should not allocate
+		Info(ctx, "processing request")
+	}
+}
+
+// Benchmark 5: full With-heavy request: several With calls and a few log lines per request
+func BenchmarkWithHeavyRequest(b *testing.B) {
+	base := slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})
+	root := ToContext(context.Background(), slog.New(base))
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		ctx := With(root, "tenant", "test-tenant", "request_id", "req-123", "method", "GET", "path", "/api/orders")
+		ctx = With(ctx, "user", "u-1", "order_id", 42)
+		for range 5 {
+			Info(ctx, "processing request")
+		}
+		ctx = With(ctx, "order_id", 43, "step", "charge")
+		for range 5 {
+			Info(ctx, "processing request")
+		}
+	}
+}
+
+// Benchmark 6: With on a context that already carries many attrs (map based merge)
+func BenchmarkWithManyAttrs(b *testing.B) {
+	base := slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})
+	ctx := ToContext(context.Background(), slog.New(base))
+	args := make([]any, 0, 32)
+	for i := range 16 {
+		args = append(args, "key"+strconv.Itoa(i), i)
+	}
+	ctx = With(ctx, args...)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_ = With(ctx, "key3", 3, "key12", 12, "extra", true)
+	}
+}
+
 // Optional: sub-benchmark varying number of attrs (useful if you want to see
 // how cost scales with context-attr count).
 // func BenchmarkAttrsInContext_VaryCount(b *testing.B) {
@@ -155,3 +211,20 @@ func BenchmarkAttrsSliceInContext_XlogHandler(b *testing.B) {
 // 	// not performance critical—only for naming the sub-benchmarks
 // 	return fmt.Sprintf("%d", i)
 // }
+
+// Benchmark 7: logging after the base logger was replaced following With
+func BenchmarkWithAfterLoggerReplacement(b *testing.B) {
+	base := slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})
+	ctx := ToContext(context.Background(), slog.New(base))
+	ctx = With(ctx, "tenant", "test-tenant", "request_id", "req-123")
+	ctx = ToContext(ctx, slog.New(base))
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		// The logger derived for the new base is cached on first use
+		Info(ctx, "processing request")
+	}
+}
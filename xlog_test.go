@@ -4,9 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"runtime"
 	"testing"
+
+	"github.com/labstack/echo/v4"
 )
 
 func Test_LoggingLevels(t *testing.T) {
@@ -152,3 +158,56 @@ func Test_XlogHandler_WithAttrsIsPreserved(t *testing.T) {
 		t.Errorf("expected tenant=test-tenant, got %v", rec["tenant"])
 	}
 }
+
+func Test_Helpers_ReportCallerSource(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug}))
+	ctx := ToContext(context.Background(), logger)
+
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), httptest.NewRecorder())
+
+	_, file, line, _ := runtime.Caller(0)
+	Info(ctx, "info")                                        // line+1
+	Error(ctx, "error", errors.New("x"))                     // line+2
+	InfoC(c, "info c")                                       // line+3
+	ErrorC(c, "error c", errors.New("x"))                    // line+4
+	LogDepth(ctx, 0, slog.LevelWarn, "depth")                // line+5
+	func() { LogDepth(ctx, 1, slog.LevelWarn, "wrapped") }() // line+6
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 log lines, got %d\n%s", len(lines), buf.String())
+	}
+	for i, l := range lines {
+		var rec struct {
+			Source slog.Source `json:"source"`
+		}
+		if err := json.Unmarshal(l, &rec); err != nil {
+			t.Fatalf("unmarshal: %v\n%s", err, l)
+		}
+		if rec.Source.File != file || rec.Source.Line != line+i+1 {
+			t.Errorf("record %d: want %s:%d, got %s:%d", i, file, line+i+1, rec.Source.File, rec.Source.Line)
+		}
+	}
+}
+
+func Test_With_SurvivesLoggerReplacement(t *testing.T) {
+	var first, second bytes.Buffer
+	ctx := ToContext(context.Background(), slog.New(slog.NewJSONHandler(&first, nil)))
+	ctx = With(ctx, "tenant", "acme")
+
+	// Replacing the logger after With must not drop the With attrs.
+	ctx = ToContext(ctx, slog.New(slog.NewJSONHandler(&second, nil)))
+	Info(ctx, "after replace")
+
+	if first.Len() != 0 {
+		t.Errorf("expected the replaced logger to stay unused, got %s", first.String())
+	}
+	if !bytes.Contains(second.Bytes(), []byte(`"tenant":"acme"`)) {
+		t.Errorf("expected tenant on the new logger, got %s", second.String())
+	}
+	if FromContext(ctx) != FromContext(ctx) {
+		t.Error("expected the logger derived for the new base to be cached")
+	}
+}